@@ -0,0 +1,67 @@
+package api
+
+import (
+	"sort"
+
+	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/i18n"
+)
+
+// Attendees returns the IDs of the users signed up for each game of the event message, keyed by the game title.
+func Attendees(message discord.WebhookWithComponent) map[string][]string {
+	attendees := make(map[string][]string)
+	if len(message.Embeds) < 2 {
+		return attendees
+	}
+	for _, field := range message.Embeds[1].Fields {
+		game := extractGameNameFromFieldName(field.Name)
+		for _, user := range stringToAttendeeList(field.Value) {
+			attendees[game] = append(attendees[game], user.UserID)
+		}
+	}
+	return attendees
+}
+
+// GameOfField returns the title of the game of a field of the embed for the attendees, e.g. "Game" for "Game (2)".
+func GameOfField(fieldName string) string {
+	return extractGameNameFromFieldName(fieldName)
+}
+
+// AttendeeChanges describes the users that signed up for or left a game between two results of Attendees,
+// with one line per user and game in the given locale, sorted by the game title.
+func AttendeeChanges(before, after map[string][]string, locale string) []string {
+	games := []string{}
+	for game := range before {
+		games = append(games, game)
+	}
+	for game := range after {
+		if _, ok := before[game]; !ok {
+			games = append(games, game)
+		}
+	}
+	sort.Strings(games)
+
+	changes := []string{}
+	for _, game := range games {
+		for _, userID := range after[game] {
+			if !contains(before[game], userID) {
+				changes = append(changes, i18n.Sprintf(locale, "%v signed up for **%v**.", userMention(userID), game))
+			}
+		}
+		for _, userID := range before[game] {
+			if !contains(after[game], userID) {
+				changes = append(changes, i18n.Sprintf(locale, "%v left **%v**.", userMention(userID), game))
+			}
+		}
+	}
+	return changes
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
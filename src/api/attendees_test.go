@@ -0,0 +1,47 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/localthomas/discord-rsvp/discordtest"
+)
+
+func TestAttendeeChanges(t *testing.T) {
+	before := map[string][]string{
+		"Game1": {testUserID},
+		"Game2": {testUserID, otherTestUserID},
+	}
+	after := map[string][]string{
+		"Game1": {testUserID, otherTestUserID},
+		"Game2": {otherTestUserID},
+	}
+	expected := []string{
+		"<@" + otherTestUserID + "> signed up for **Game1**.",
+		"<@" + testUserID + "> left **Game2**.",
+	}
+	if changes := AttendeeChanges(before, after, "en-US"); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected changes %q, got %q", expected, changes)
+	}
+	if changes := AttendeeChanges(after, after, "en-US"); len(changes) != 0 {
+		t.Errorf("expected no changes, got %q", changes)
+	}
+}
+
+func TestAttendees(t *testing.T) {
+	signer := discordtest.NewSigner()
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	message := discordtest.EventMessage("Event", addGame1)
+	if attendees := Attendees(message.WebhookWithComponent); len(attendees) != 0 {
+		t.Errorf("expected no attendees, got %v", attendees)
+	}
+
+	message = updatedMessage(t, message, press(t, signer, addGame1, testUserID, message))
+	message = updatedMessage(t, message, press(t, signer, addGame1, otherTestUserID, message))
+	expected := map[string][]string{
+		"Game1": {testUserID, otherTestUserID},
+	}
+	if attendees := Attendees(message.WebhookWithComponent); !reflect.DeepEqual(attendees, expected) {
+		t.Errorf("expected attendees %v, got %v", expected, attendees)
+	}
+}
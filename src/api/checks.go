@@ -0,0 +1,78 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/i18n"
+)
+
+// InteractionCheck decides if the user of an interaction is allowed to use a component.
+// Checks are added to handlers via the Check middleware.
+type InteractionCheck func(interaction discord.Interaction) (allowed bool, reason string)
+
+// RequireRoles returns a check that only allows members with at least one of the roles returned by allowedRoles.
+// If allowedRoles returns an empty list, everyone is allowed. If it returns false, since the event of the interaction
+// is unknown, nobody is allowed.
+func RequireRoles(allowedRoles func(interaction discord.Interaction) ([]string, bool)) InteractionCheck {
+	return func(interaction discord.Interaction) (bool, string) {
+		roles, ok := allowedRoles(interaction)
+		if !ok {
+			return false, i18n.Translate(interaction.Locale, "This event is not available anymore.")
+		}
+		if len(roles) == 0 {
+			return true, ""
+		}
+		for _, role := range roles {
+			for _, memberRole := range interaction.Member.Roles {
+				if role == memberRole {
+					return true, ""
+				}
+			}
+		}
+		roleMentions := make([]string, 0, len(roles))
+		for _, role := range roles {
+			roleMentions = append(roleMentions, roleMention(role))
+		}
+		return false, i18n.Sprintf(interaction.Locale, "This event is restricted to members with one of these roles: %v", strings.Join(roleMentions, ", "))
+	}
+}
+
+// permissionAdministrator and permissionManageEvents are bits of the permissions of a member.
+// https://discord.com/developers/docs/topics/permissions#permissions-bitwise-permission-flags
+const permissionAdministrator = 1 << 3
+const permissionManageEvents = 1 << 33
+
+// RequireOrganiser returns a check that only allows members with the Administrator or Manage Events permission
+// or with at least one of the given organiser roles.
+func RequireOrganiser(organiserRoles []string) InteractionCheck {
+	return func(interaction discord.Interaction) (bool, string) {
+		// Note: the permissions are serialized as string, since they do not fit into 53 bits
+		permissions, err := strconv.ParseUint(interaction.Member.Permissions, 10, 64)
+		if err == nil && permissions&(permissionAdministrator|permissionManageEvents) != 0 {
+			return true, ""
+		}
+		for _, role := range organiserRoles {
+			for _, memberRole := range interaction.Member.Roles {
+				if role == memberRole {
+					return true, ""
+				}
+			}
+		}
+		return false, i18n.Translate(interaction.Locale, "Only organisers can use this.")
+	}
+}
+
+// RequireOpenRsvp returns a check that rejects interactions after the RSVP deadline returned by closesAt.
+// If closesAt returns false, the event has no deadline.
+func RequireOpenRsvp(closesAt func(interaction discord.Interaction) (time.Time, bool)) InteractionCheck {
+	return func(interaction discord.Interaction) (bool, string) {
+		deadline, ok := closesAt(interaction)
+		if ok && !time.Now().Before(deadline) {
+			return false, i18n.Translate(interaction.Locale, closedReply)
+		}
+		return true, ""
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/discordtest"
+)
+
+func TestRequireRoles(t *testing.T) {
+	events := map[string][]string{
+		"restricted":   {"846700000000000001", "846700000000000002"},
+		"unrestricted": nil,
+	}
+	check := RequireRoles(func(interaction discord.Interaction) ([]string, bool) {
+		roles, ok := events[interaction.Message.Embeds[0].Title]
+		return roles, ok
+	})
+
+	tests := []struct {
+		event   string
+		roles   []string
+		allowed bool
+	}{
+		{event: "unrestricted", roles: []string{}, allowed: true},
+		{event: "restricted", roles: []string{"846700000000000002"}, allowed: true},
+		{event: "restricted", roles: []string{"846700000000000003"}, allowed: false},
+		{event: "restricted", roles: []string{}, allowed: false},
+		// Note: events that are not known anymore are denied, since their roles can not be checked
+		{event: "unknown", roles: []string{"846700000000000001"}, allowed: false},
+	}
+	for _, test := range tests {
+		interaction := discordtest.ButtonInteraction("button", testUserID, discordtest.EventMessage(test.event))
+		interaction.Member.Roles = test.roles
+		allowed, reason := check(interaction)
+		if allowed != test.allowed {
+			t.Errorf("expected allowed to be %v for event %v and roles %v, got %v", test.allowed, test.event, test.roles, allowed)
+		}
+		if !allowed && reason == "" {
+			t.Errorf("expected a reason for denying event %v and roles %v", test.event, test.roles)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// customIDVersion is the version of the custom_id format created by CustomIDCodec.Encode.
+// Increase it when the arguments of an action change in an incompatible way.
+const customIDVersion = 1
+
+// customIDPrefix marks the versioned format. Legacy custom_ids start directly with the action.
+const customIDPrefix = "~"
+const customIDSeparator = "|"
+
+// customIDLookupPrefix marks an argument that was replaced by a short ID, see CustomIDCodec.
+const customIDLookupPrefix = "#"
+
+// maxCustomIDLength is the maximum length of a custom_id accepted by Discord.
+const maxCustomIDLength = 100
+
+// CustomID is the decoded form of the custom_id of a component or modal.
+type CustomID struct {
+	// Action selects the handler of the interaction, e.g. CustomIDButtonAddUserToGame
+	Action string
+	// Version is the version of the format; 0 for legacy custom_ids
+	Version int
+	// Args contains the arguments of the action
+	Args []string
+}
+
+// Argument returns the first argument or an empty string, if there are no arguments.
+func (c CustomID) Argument() string {
+	if len(c.Args) == 0 {
+		return ""
+	}
+	return c.Args[0]
+}
+
+// CustomIDCodec encodes and decodes custom_ids, whose arguments are replaced by short IDs, if they would exceed
+// the length limit or can not be represented literally. The short IDs are not persisted, so the codec must know all
+// arguments that may be replaced, e.g. the titles of the games. Otherwise custom_ids of messages created before
+// a restart can not be decoded anymore.
+type CustomIDCodec struct {
+	// arguments maps the short IDs to the arguments
+	arguments map[string]string
+}
+
+// NewCustomIDCodec creates a codec, which can replace the given arguments by short IDs.
+func NewCustomIDCodec(arguments ...string) CustomIDCodec {
+	codec := CustomIDCodec{
+		arguments: make(map[string]string, len(arguments)),
+	}
+	for _, argument := range arguments {
+		codec.arguments[customIDLookupID(argument)] = argument
+	}
+	return codec
+}
+
+func customIDLookupID(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return customIDLookupPrefix + base64.RawURLEncoding.EncodeToString(sum[:6])
+}
+
+// shortID returns the short ID of an argument known to the codec.
+// Arguments that are not known would be lost after a restart, which is a programming error.
+func (c CustomIDCodec) shortID(value string) string {
+	id := customIDLookupID(value)
+	if c.arguments[id] != value {
+		panic(fmt.Sprintf("custom_id argument %q must be passed to NewCustomIDCodec", value))
+	}
+	return id
+}
+
+// EncodeCustomID creates a versioned custom_id for the action and its arguments, which must be short
+// and must not contain the separator. Other arguments are encoded via CustomIDCodec.Encode.
+func EncodeCustomID(action string, args ...string) string {
+	return CustomIDCodec{}.Encode(action, args...)
+}
+
+// Encode creates a versioned custom_id for the action and its arguments.
+// Arguments that would exceed the length limit or that can not be represented literally are replaced by short IDs,
+// which requires them to be known to the codec.
+func (c CustomIDCodec) Encode(action string, args ...string) string {
+	encodedArgs := make([]string, len(args))
+	for index, arg := range args {
+		if strings.Contains(arg, customIDSeparator) || strings.HasPrefix(arg, customIDLookupPrefix) {
+			encodedArgs[index] = c.shortID(arg)
+		} else {
+			encodedArgs[index] = arg
+		}
+	}
+	customID := joinCustomID(action, encodedArgs)
+	// replace the longest arguments first, until the custom_id is short enough
+	for len(customID) > maxCustomIDLength {
+		longest := -1
+		for index, arg := range encodedArgs {
+			if !strings.HasPrefix(arg, customIDLookupPrefix) && (longest < 0 || len(arg) > len(encodedArgs[longest])) {
+				longest = index
+			}
+		}
+		if longest < 0 {
+			// Note: only the action itself can be too long, which is a programming error
+			break
+		}
+		encodedArgs[longest] = c.shortID(args[longest])
+		customID = joinCustomID(action, encodedArgs)
+	}
+	return customID
+}
+
+func joinCustomID(action string, args []string) string {
+	parts := append([]string{customIDPrefix + strconv.Itoa(customIDVersion), action}, args...)
+	return strings.Join(parts, customIDSeparator)
+}
+
+// Decode parses a custom_id created by Encode.
+// Legacy custom_ids in the form "action free text" are decoded with version 0 and the free text as single argument.
+func (c CustomIDCodec) Decode(raw string) (CustomID, error) {
+	customID, err := parseCustomID(raw)
+	if err != nil {
+		return CustomID{}, err
+	}
+	for index, arg := range customID.Args {
+		if customID.Version > 0 && strings.HasPrefix(arg, customIDLookupPrefix) {
+			value, ok := c.arguments[arg]
+			if !ok {
+				return CustomID{}, fmt.Errorf("custom_id %q contains the unknown argument %v", raw, arg)
+			}
+			customID.Args[index] = value
+		}
+	}
+	return customID, nil
+}
+
+// parseCustomID splits a custom_id into its parts, without replacing short IDs by their arguments.
+func parseCustomID(raw string) (CustomID, error) {
+	if !strings.HasPrefix(raw, customIDPrefix) {
+		// legacy format: "command_with_underscores After the first whitespace, free text follows"
+		splitted := strings.SplitN(raw, " ", 2)
+		customID := CustomID{
+			Action: splitted[0],
+		}
+		if len(splitted) > 1 {
+			customID.Args = []string{splitted[1]}
+		}
+		return customID, nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(raw, customIDPrefix), customIDSeparator)
+	if len(parts) < 2 {
+		return CustomID{}, fmt.Errorf("custom_id %q has no action", raw)
+	}
+	version, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return CustomID{}, fmt.Errorf("custom_id %q has an invalid version: %w", raw, err)
+	}
+	if version > customIDVersion {
+		return CustomID{}, fmt.Errorf("custom_id %q has the unsupported version %v", raw, version)
+	}
+	return CustomID{
+		Action:  parts[1],
+		Version: version,
+		Args:    append([]string{}, parts[2:]...),
+	}, nil
+}
+
+// customIDAction returns the action of a custom_id or the raw custom_id, if it can not be decoded.
+func customIDAction(raw string) string {
+	customID, err := parseCustomID(raw)
+	if err != nil {
+		return raw
+	}
+	return customID.Action
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCustomIDRoundTrip(t *testing.T) {
+	raw := EncodeCustomID("action", "Game1", "second argument")
+	if raw != "~1|action|Game1|second argument" {
+		t.Errorf("unexpected custom_id %q", raw)
+	}
+	decoded, err := NewCustomIDCodec().Decode(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := CustomID{Action: "action", Version: 1, Args: []string{"Game1", "second argument"}}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("expected %+v, got %+v", expected, decoded)
+	}
+}
+
+func TestCustomIDWithKnownArguments(t *testing.T) {
+	withSeparator := "Game|with separator"
+	long := strings.Repeat("Long Game ", 12)
+	codec := NewCustomIDCodec(withSeparator, long)
+
+	for _, arg := range []string{withSeparator, long} {
+		raw := codec.Encode("action", arg)
+		if len(raw) > maxCustomIDLength || strings.Count(raw, customIDSeparator) != 2 {
+			t.Errorf("expected the argument %q to be replaced by a short ID, got %q", arg, raw)
+		}
+		decoded, err := codec.Decode(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Argument() != arg {
+			t.Errorf("expected argument %q, got %q", arg, decoded.Argument())
+		}
+	}
+}
+
+func TestCustomIDWithUnknownArgument(t *testing.T) {
+	long := strings.Repeat("Long Game ", 12)
+	raw := NewCustomIDCodec(long).Encode("action", long)
+	// another configuration does not know the argument
+	if decoded, err := NewCustomIDCodec().Decode(raw); err == nil {
+		t.Errorf("expected an error for the unknown argument, got %+v", decoded)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected encoding an unknown long argument to panic")
+		}
+	}()
+	EncodeCustomID("action", strings.Repeat("Unknown Game ", 10))
+}
+
+func TestDecodeInvalidCustomIDs(t *testing.T) {
+	for _, raw := range []string{
+		// the short ID is not known, e.g. since the game was removed
+		"~1|action|#AAAAAAAA",
+		// the format of a newer version
+		"~2|action|Game1",
+		"~x|action",
+		"~1",
+	} {
+		if decoded, err := NewCustomIDCodec().Decode(raw); err == nil {
+			t.Errorf("expected an error for custom_id %q, got %+v", raw, decoded)
+		}
+	}
+}
+
+func TestDecodeLegacyCustomID(t *testing.T) {
+	codec := NewCustomIDCodec()
+	decoded, err := codec.Decode("add_user_to_game Game 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := CustomID{Action: "add_user_to_game", Version: 0, Args: []string{"Game 1"}}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Errorf("expected %+v, got %+v", expected, decoded)
+	}
+
+	decoded, err = codec.Decode("remove_user_from_event")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Action != "remove_user_from_event" || len(decoded.Args) != 0 {
+		t.Errorf("expected the action without arguments, got %+v", decoded)
+	}
+}
@@ -2,15 +2,19 @@ package api
 
 import (
 	"fmt"
-	"net/http"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/i18n"
 )
 
-func HandleAddUserToGame(w http.ResponseWriter, interaction discord.ButtonInteraction, argument string) {
+func (m EventMessages) HandleAddUserToGame(interaction discord.Interaction, argument string) InteractionResponse {
+	if isLocked(interaction.Message.Components) {
+		return EphemeralReply(i18n.Translate(interaction.Locale, lockedReply))
+	}
 	embed := &discordgo.MessageEmbed{}
 	if len(interaction.Message.Embeds) > 1 {
 		embed = interaction.Message.Embeds[1]
@@ -18,19 +22,12 @@ func HandleAddUserToGame(w http.ResponseWriter, interaction discord.ButtonIntera
 		interaction.Message.Embeds = append(interaction.Message.Embeds, embed)
 	}
 
-	embed.Title = "Attendees"
-	embed.Color = 0x3ba55d
-
 	// add the user that pressed the button to the embed with all users that were added to a game
 	userID := interaction.Member.User.ID
-	var field *discordgo.MessageEmbedField
+	// Note: the capacity of a game might have been raised since the waitlist was created
+	m.promoteWaitlistedUsers(interaction.Message, messageLocale(interaction))
 	// find the field for the game
-	for _, fieldToTest := range embed.Fields {
-		if strings.HasPrefix(fieldToTest.Name, argument) {
-			field = fieldToTest
-			break
-		}
-	}
+	field := findGameField(embed, argument)
 	if field == nil {
 		// create a new field with default values
 		field = &discordgo.MessageEmbedField{
@@ -40,41 +37,102 @@ func HandleAddUserToGame(w http.ResponseWriter, interaction discord.ButtonIntera
 		embed.Fields = append(embed.Fields, field)
 	}
 
-	users := stringToUserList(field.Value)
+	users := stringToAttendeeList(field.Value)
 	// check if user is already in the list
-	alreadyExists := false
 	for _, user := range users {
-		if user == userID {
-			alreadyExists = true
-			break
+		if user.UserID == userID {
+			return EphemeralReply(i18n.Sprintf(interaction.Locale, "You are already signed up for %v.", argument))
 		}
 	}
-	if !alreadyExists {
-		users = append(users, userID)
+	user := attendee{
+		UserID: userID,
+		// a note that was already added for another game is shown for this game as well
+		Note: findNote(embed, userID),
+	}
+	if capacity := m.gameCapacity(argument); capacity > 0 && len(users) >= capacity {
+		return m.addUserToWaitlist(interaction, argument, user)
 	}
-	field.Value = userListToString(users)
+	user, fits := fitAttendee(users, user)
+	if !fits {
+		return EphemeralReply(i18n.Sprintf(interaction.Locale, "The list of attendees for %v is full.", argument))
+	}
+	users = append(users, user)
+	field.Value = attendeeListToString(users)
 	// set the field title to "Game (2)", where 2 is the number of users (attendees)
 	field.Name = argument + fmt.Sprintf(" (%v)", len(users))
+	m.applyAttendeeLayout(embed, messageLocale(interaction))
 
-	writeResponse(w, interaction.Message)
+	refreshAttendeeSelect(interaction.Message, messageLocale(interaction), map[string]string{
+		userID: memberName(interaction),
+	})
+	return UpdateMessage(interaction.Message.WebhookWithComponent)
 }
 
-func HandleRemoveUserFromEvent(w http.ResponseWriter, interaction discord.ButtonInteraction, argument string) {
+// addUserToWaitlist puts the user that pressed the button of a full game on its waitlist.
+func (m EventMessages) addUserToWaitlist(interaction discord.Interaction, game string, user attendee) InteractionResponse {
+	position, added := addToWaitlist(interaction.Message, messageLocale(interaction), game, user)
+	if !added && position == 0 {
+		return EphemeralReply(i18n.Sprintf(interaction.Locale, "The waitlist for %v is full.", game))
+	}
+	if !added {
+		return EphemeralReply(i18n.Sprintf(interaction.Locale, "You are already on the waitlist for %v at position %v.", game, position))
+	}
+	refreshAttendeeSelect(interaction.Message, messageLocale(interaction), map[string]string{
+		user.UserID: memberName(interaction),
+	})
+	response := UpdateMessage(interaction.Message.WebhookWithComponent)
+	response.Ephemeral = i18n.Sprintf(interaction.Locale, "%v is full, you were put on the waitlist at position %v.", game, position)
+	return response
+}
+
+func (m EventMessages) HandleRemoveUserFromEvent(interaction discord.Interaction, argument string) InteractionResponse {
+	if isLocked(interaction.Message.Components) {
+		return EphemeralReply(i18n.Translate(interaction.Locale, lockedReply))
+	}
+	// remove the user that pressed the button from all the fields
+	if !m.removeUserFromEvent(interaction.Message, interaction.Member.User.ID, messageLocale(interaction)) {
+		return EphemeralReply(i18n.Translate(interaction.Locale, "You are not signed up for any game."))
+	}
+	refreshAttendeeSelect(interaction.Message, messageLocale(interaction), nil)
+	return UpdateMessage(interaction.Message.WebhookWithComponent)
+}
+
+// removeUserFromEvent removes the user from the fields of all games and their waitlists and reports if the user was found.
+// Users on the waitlists are promoted to the games that are not full anymore.
+// The layout of the embed for the attendees is applied in the given locale.
+func (m EventMessages) removeUserFromEvent(message *discord.Message, userID, locale string) bool {
 	var embed *discordgo.MessageEmbed
-	if len(interaction.Message.Embeds) > 1 {
-		embed = interaction.Message.Embeds[1]
+	if len(message.Embeds) > 1 {
+		embed = message.Embeds[1]
 	} else {
 		// do nothing, if no embed for the attendees was found
-		writeResponse(w, interaction.Message)
-		return
+		return false
 	}
 
-	// remove the user that pressed the button from all the fields
-	userID := interaction.Member.User.ID
+	wasRemoved := removeUserFromFields(embed, userID)
+	if waitlist := waitlistEmbed(message, locale, false); waitlist != nil && removeUserFromFields(waitlist, userID) {
+		wasRemoved = true
+	}
+	m.promoteWaitlistedUsers(message, locale)
+
+	// special case: if no fields are on the embed and there is no waitlist, remove it
+	if len(embed.Fields) == 0 && len(message.Embeds) == 2 {
+		message.Embeds = message.Embeds[:1]
+	} else if wasRemoved {
+		m.applyAttendeeLayout(embed, locale)
+	}
+	return wasRemoved
+}
+
+// removeUserFromFields removes the user from all fields of the embed and reports if the user was found.
+// Fields without users are removed.
+func removeUserFromFields(embed *discordgo.MessageEmbed, userID string) bool {
+	wasRemoved := false
 	for i := 0; i < len(embed.Fields); i++ {
-		users := stringToUserList(embed.Fields[i].Value)
+		users := stringToAttendeeList(embed.Fields[i].Value)
 		for index, user := range users {
-			if user == userID {
+			if user.UserID == userID {
+				wasRemoved = true
 				if index+1 == len(users) {
 					users = users[:index]
 				} else {
@@ -93,18 +151,105 @@ func HandleRemoveUserFromEvent(w http.ResponseWriter, interaction discord.Button
 			}
 			i-- // since one field was removed, the list length is now -1 and the next element got a new index: i-1
 		} else {
-			embed.Fields[i].Value = userListToString(users)
+			embed.Fields[i].Value = attendeeListToString(users)
 			// set the field title to "Game (2)", where 2 is the number of users (attendees)
 			embed.Fields[i].Name = extractGameNameFromFieldName(embed.Fields[i].Name) + fmt.Sprintf(" (%v)", len(users))
 		}
 	}
+	return wasRemoved
+}
+
+// HandleShowNoteModal opens a modal dialog, in which the user can enter a note that is shown next to their name.
+func (m EventMessages) HandleShowNoteModal(interaction discord.Interaction, argument string) InteractionResponse {
+	if isLocked(interaction.Message.Components) {
+		return EphemeralReply(i18n.Translate(interaction.Locale, lockedReply))
+	}
+	embed, _ := extractEmbed(interaction)
+	return InteractionResponse{
+		Modal: &discord.Modal{
+			CustomID: EncodeCustomID(CustomIDModalSubmitNote),
+			Title:    i18n.Translate(interaction.Locale, "Add Note"),
+			Components: []discord.Component{
+				{
+					Type: discord.ComponentTypeActionRow,
+					Components: []discord.Component{
+						{
+							Type:        discord.ComponentTypeTextInput,
+							CustomID:    CustomIDTextInputNote,
+							Label:       i18n.Translate(interaction.Locale, "Note"),
+							Style:       discord.TextInputStyleShort,
+							Placeholder: i18n.Translate(interaction.Locale, "e.g. joining late at 21:00"),
+							// pre-fill the modal with the current note of the user
+							Value:     findNote(embed, interaction.Member.User.ID),
+							MaxLength: maxNoteLength,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// HandleSubmitNote sets the note of the user that submitted the modal for all games the user was added to.
+// An empty note removes any existing note.
+func (m EventMessages) HandleSubmitNote(interaction discord.Interaction, argument string) InteractionResponse {
+	if isLocked(interaction.Message.Components) {
+		return EphemeralReply(i18n.Translate(interaction.Locale, lockedReply))
+	}
+	embed, _ := extractEmbed(interaction)
+
+	note := sanitizeNote(interaction.ModalValue(CustomIDTextInputNote))
+	userID := interaction.Member.User.ID
+	wasFound := false
+	fields := embed.Fields
+	// Note: the note is shown on the waitlists as well
+	if waitlist := waitlistEmbed(interaction.Message, messageLocale(interaction), false); waitlist != nil {
+		fields = append(append([]*discordgo.MessageEmbedField{}, fields...), waitlist.Fields...)
+	}
+	// the values are only changed, once the note fits into all fields of the user
+	values := make([]string, len(fields))
+	for i, field := range fields {
+		users := stringToAttendeeList(field.Value)
+		for index := range users {
+			if users[index].UserID == userID {
+				users[index].Note = note
+				wasFound = true
+			}
+		}
+		values[i] = attendeeListToString(users)
+		if !fitsField(values[i]) {
+			return EphemeralReply(i18n.Sprintf(interaction.Locale, "The note does not fit into the list for %v, please use a shorter note.", extractGameNameFromFieldName(field.Name)))
+		}
+	}
 
-	// special case: if no fields are on the embed, remove it
-	if len(embed.Fields) == 0 {
-		interaction.Message.Embeds = interaction.Message.Embeds[:1]
+	if !wasFound {
+		return EphemeralReply(i18n.Translate(interaction.Locale, "Select a game first, before adding a note."))
 	}
+	for i, field := range fields {
+		field.Value = values[i]
+	}
+	return UpdateMessage(interaction.Message.WebhookWithComponent)
+}
 
-	writeResponse(w, interaction.Message)
+// findNote returns the note of the user with the given ID from the first field the user was found in.
+func findNote(embed *discordgo.MessageEmbed, userID string) string {
+	for _, field := range embed.Fields {
+		for _, user := range stringToAttendeeList(field.Value) {
+			if user.UserID == userID && user.Note != "" {
+				return user.Note
+			}
+		}
+	}
+	return ""
+}
+
+// sanitizeNote ensures that a note fits into a single line of the attendee list.
+func sanitizeNote(note string) string {
+	note = strings.Join(strings.Fields(note), " ")
+	if len([]rune(note)) > maxNoteLength {
+		note = string([]rune(note)[:maxNoteLength])
+	}
+	return note
 }
 
 func extractGameNameFromFieldName(fieldName string) string {
@@ -117,7 +262,7 @@ func extractGameNameFromFieldName(fieldName string) string {
 	}
 }
 
-func extractEmbed(interaction discord.ButtonInteraction) (*discordgo.MessageEmbed, error) {
+func extractEmbed(interaction discord.Interaction) (*discordgo.MessageEmbed, error) {
 	// extract the current embed from the interaction
 	var embed *discordgo.MessageEmbed
 	if len(interaction.Message.Embeds) > 1 {
@@ -128,48 +273,96 @@ func extractEmbed(interaction discord.ButtonInteraction) (*discordgo.MessageEmbe
 	return embed, nil
 }
 
-func writeResponse(w http.ResponseWriter, message discord.WebhookWithComponent) {
-	response := discord.ButtonInteractionResponse{
-		Type: 7,
-		Data: message,
-	}
-	err := writeJSON(w, response)
-	if err != nil {
-		fmt.Printf("could not write interaction response: %v\n", err)
+func userMention(userID string) string {
+	return fmt.Sprintf("<@%v>", userID)
+}
+
+func roleMention(roleID string) string {
+	return fmt.Sprintf("<@&%v>", roleID)
+}
+
+// messageLocale returns the locale of the texts of the event message, which are visible to everyone.
+// Replies that are only visible to the user use the locale of the interaction instead. See MessageLocale.
+// Without the middleware, the preferred language of the guild is used.
+func messageLocale(interaction discord.Interaction) string {
+	if interaction.MessageLocale != "" {
+		return interaction.MessageLocale
 	}
+	return interaction.GuildLocale
 }
 
-func userMention(userID string) string {
-	return fmt.Sprintf("<@%v>", userID)
+// memberName returns the name of the user that triggered the interaction as shown in the guild.
+func memberName(interaction discord.Interaction) string {
+	return interaction.Member.DisplayName()
 }
 
 const userListSplitValue = "\n"
+const noteSplitValue = ": "
+const maxNoteLength = 100
+
+// maxFieldValueLength is the maximum number of characters of the value of an embed field allowed by Discord
+const maxFieldValueLength = 1024
+
+// attendee is a single entry in the list of users for a game
+type attendee struct {
+	UserID string
+	// Note is an optional free text that is shown next to the user mention
+	Note string
+}
 
-// stringToUserList converts the given string to a list of user IDs.
-// Use userListToString for the reverse operation.
-func stringToUserList(value string) []string {
-	// userID mention format: <@12345>
+// stringToAttendeeList converts the given string to a list of attendees.
+// Use attendeeListToString for the reverse operation.
+func stringToAttendeeList(value string) []attendee {
+	// attendee format: <@12345> or <@12345>: note
 	usersRaw := strings.Split(value, userListSplitValue)
-	userIDs := make([]string, 0)
+	attendees := make([]attendee, 0)
 	for i := range usersRaw {
-		userID := strings.Trim(usersRaw[i], "<>")
+		mention := usersRaw[i]
+		note := ""
+		if end := strings.Index(mention, ">"); end >= 0 {
+			note = strings.TrimPrefix(mention[end+1:], noteSplitValue)
+			mention = mention[:end+1]
+		}
+		userID := strings.Trim(mention, "<>")
 		userID = strings.TrimPrefix(userID, "@")
 		// Note: skip empty user IDs
 		if userID != "" {
-			userIDs = append(userIDs, userID)
+			attendees = append(attendees, attendee{
+				UserID: userID,
+				Note:   note,
+			})
 		}
 	}
-	return userIDs
+	return attendees
+}
+
+// fitsField reports if the value can be used for a field of an embed.
+func fitsField(value string) bool {
+	return utf8.RuneCountInString(value) <= maxFieldValueLength
+}
+
+// fitAttendee returns the user as it can be added to the end of the list of users and reports if the user fits into the field.
+// If the field is too long for the note of the user, the user is added without the note.
+func fitAttendee(users []attendee, user attendee) (attendee, bool) {
+	if fitsField(attendeeListToString(append(users, user))) {
+		return user, true
+	}
+	user.Note = ""
+	return user, fitsField(attendeeListToString(append(users, user)))
 }
 
-// userListToString converts the given ids to a single string of user mentions.
-// Use stringToUserList for the reverse operation.
-func userListToString(userIDs []string) string {
+// attendeeListToString converts the given attendees to a single string of user mentions with their notes.
+// Use stringToAttendeeList for the reverse operation.
+func attendeeListToString(attendees []attendee) string {
 	userMentions := make([]string, 0)
-	for _, userID := range userIDs {
+	for _, user := range attendees {
 		// Note: skip empty user IDs
-		if userID != "" {
-			userMentions = append(userMentions, userMention(userID))
+		if user.UserID != "" {
+			line := userMention(user.UserID)
+			if user.Note != "" {
+				line += noteSplitValue + user.Note
+			}
+			userMentions = append(userMentions, line)
 		}
 	}
 	return strings.Join(userMentions, userListSplitValue)
@@ -0,0 +1,299 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/discordtest"
+)
+
+const testUserID = "846600000000000001"
+const otherTestUserID = "846600000000000002"
+
+// press sends the interaction for a button below the message and returns the decoded response.
+func press(t *testing.T, signer *discordtest.Signer, customID, userID string, message discord.Message) discord.InteractionResponse {
+	t.Helper()
+	return pressAt(t, newTestEndpoint(t, signer), signer, customID, userID, message)
+}
+
+// pressAt sends the interaction for a button below the message to the endpoint and returns the decoded response.
+func pressAt(t *testing.T, endpoint http.Handler, signer *discordtest.Signer, customID, userID string, message discord.Message) discord.InteractionResponse {
+	t.Helper()
+	recorder := signer.Send(endpoint, discordtest.ButtonInteraction(customID, userID, message))
+	response, err := discordtest.DecodeResponse(recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+// updatedMessage returns the message of an update response for the next interaction.
+func updatedMessage(t *testing.T, previous discord.Message, response discord.InteractionResponse) discord.Message {
+	t.Helper()
+	if response.Type != discord.InteractionResponseUpdateMessage {
+		t.Fatalf("expected message update (type 7), got %+v", response)
+	}
+	return discord.Message{
+		ID:                   previous.ID,
+		WebhookWithComponent: response.Data.WebhookWithComponent,
+	}
+}
+
+func TestHandleAddUserToGame(t *testing.T) {
+	signer := discordtest.NewSigner()
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	message := discordtest.EventMessage("Event", addGame1)
+
+	message = updatedMessage(t, message, press(t, signer, addGame1, testUserID, message))
+	message = updatedMessage(t, message, press(t, signer, addGame1, otherTestUserID, message))
+
+	if len(message.Embeds) != 2 {
+		t.Fatalf("expected an embed for the attendees, got %v embeds", len(message.Embeds))
+	}
+	fields := message.Embeds[1].Fields
+	if len(fields) != 1 {
+		t.Fatalf("expected one field for Game1, got %v fields", len(fields))
+	}
+	if fields[0].Name != "Game1 (2)" {
+		t.Errorf("expected field name %q, got %q", "Game1 (2)", fields[0].Name)
+	}
+	expectedValue := "<@" + testUserID + ">\n<@" + otherTestUserID + ">"
+	if fields[0].Value != expectedValue {
+		t.Errorf("expected field value %q, got %q", expectedValue, fields[0].Value)
+	}
+}
+
+func TestHandleAddUserToGameTwice(t *testing.T) {
+	signer := discordtest.NewSigner()
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	message := discordtest.EventMessage("Event", addGame1)
+
+	message = updatedMessage(t, message, press(t, signer, addGame1, testUserID, message))
+	response := press(t, signer, addGame1, testUserID, message)
+
+	if response.Type != 4 || response.Data.Flags != discord.MessageFlagEphemeral {
+		t.Fatalf("expected ephemeral reply, got %+v", response)
+	}
+	if !strings.Contains(response.Data.Content, "already signed up") {
+		t.Errorf("unexpected reply %q", response.Data.Content)
+	}
+}
+
+func TestHandlerLocales(t *testing.T) {
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	message := discordtest.EventMessage("Event", addGame1)
+	interaction := discordtest.ButtonInteraction(addGame1, testUserID, message)
+	interaction.Locale = "en-US"
+	interaction.GuildLocale = "en-US"
+	middleware := MessageLocale(func(interaction discord.Interaction) string {
+		return "de"
+	})
+	handler := middleware(EventMessages{}.HandleAddUserToGame)
+
+	// the locale of the guild sent by Discord is kept
+	middleware(func(passed discord.Interaction, argument string) InteractionResponse {
+		if passed.MessageLocale != "de" || passed.GuildLocale != "en-US" {
+			t.Errorf("expected message locale de and guild locale en-US, got %q and %q", passed.MessageLocale, passed.GuildLocale)
+		}
+		return InteractionResponse{}
+	})(interaction, "Game1")
+
+	// the event message uses the locale of the event
+	response := handler(interaction, "Game1")
+	if title := response.Update.Embeds[1].Title; title != "Teilnehmer" {
+		t.Errorf("expected the attendees in the locale of the event, got %q", title)
+	}
+
+	// replies use the locale of the user
+	interaction.Message.WebhookWithComponent = *response.Update
+	interaction.Locale = "de"
+	response = handler(interaction, "Game1")
+	if response.Ephemeral != "Du bist bereits für Game1 angemeldet." {
+		t.Errorf("expected the reply in the locale of the user, got %q", response.Ephemeral)
+	}
+}
+
+func TestHandleAddUserToLegacyCustomID(t *testing.T) {
+	signer := discordtest.NewSigner()
+	// custom_id format of messages created before the custom_id codec
+	addGame1 := CustomIDButtonAddUserToGame + " Game with spaces"
+	message := discordtest.EventMessage("Event", addGame1)
+
+	message = updatedMessage(t, message, press(t, signer, addGame1, testUserID, message))
+	if name := message.Embeds[1].Fields[0].Name; name != "Game with spaces (1)" {
+		t.Errorf("expected field name %q, got %q", "Game with spaces (1)", name)
+	}
+}
+
+func TestHandleRemoveUserFromEvent(t *testing.T) {
+	signer := discordtest.NewSigner()
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	addGame2 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game2")
+	remove := EncodeCustomID(CustomIDButtonRemoveUserFromEvent)
+	message := discordtest.EventMessage("Event", addGame1, addGame2, remove)
+
+	message = updatedMessage(t, message, press(t, signer, addGame1, testUserID, message))
+	message = updatedMessage(t, message, press(t, signer, addGame2, testUserID, message))
+	message = updatedMessage(t, message, press(t, signer, addGame2, otherTestUserID, message))
+	message = updatedMessage(t, message, press(t, signer, remove, testUserID, message))
+
+	fields := message.Embeds[1].Fields
+	if len(fields) != 1 {
+		t.Fatalf("expected only the field for Game2, got %v fields", len(fields))
+	}
+	if fields[0].Name != "Game2 (1)" || fields[0].Value != "<@"+otherTestUserID+">" {
+		t.Errorf("unexpected field %q with value %q", fields[0].Name, fields[0].Value)
+	}
+
+	// removing the last attendee removes the embed for the attendees
+	message = updatedMessage(t, message, press(t, signer, remove, otherTestUserID, message))
+	if len(message.Embeds) != 1 {
+		t.Errorf("expected only the embed of the event, got %v embeds", len(message.Embeds))
+	}
+}
+
+func TestHandleRemoveUserFromEventWithoutSignUp(t *testing.T) {
+	signer := discordtest.NewSigner()
+	remove := EncodeCustomID(CustomIDButtonRemoveUserFromEvent)
+	message := discordtest.EventMessage("Event", remove)
+
+	response := press(t, signer, remove, testUserID, message)
+	if response.Type != 4 || response.Data.Flags != discord.MessageFlagEphemeral {
+		t.Errorf("expected ephemeral reply, got %+v", response)
+	}
+}
+
+// submitNote sends the modal for the note of the user and returns the decoded response.
+func submitNote(t *testing.T, signer *discordtest.Signer, note, userID string, message discord.Message) discord.InteractionResponse {
+	t.Helper()
+	endpoint := newTestEndpoint(t, signer)
+	interaction := discordtest.ModalSubmitInteraction(EncodeCustomID(CustomIDModalSubmitNote), userID, map[string]string{
+		CustomIDTextInputNote: note,
+	}, message)
+	response, err := discordtest.DecodeResponse(signer.Send(endpoint, interaction))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestHandleShowNoteModal(t *testing.T) {
+	signer := discordtest.NewSigner()
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	addNote := EncodeCustomID(CustomIDButtonAddNote)
+	message := discordtest.EventMessage("Event", addGame1, addNote)
+	message = updatedMessage(t, message, press(t, signer, addGame1, testUserID, message))
+	message = updatedMessage(t, message, submitNote(t, signer, "late", testUserID, message))
+
+	response := press(t, signer, addNote, testUserID, message)
+	if response.Type != discord.InteractionResponseModal {
+		t.Fatalf("expected modal (type 9), got %+v", response)
+	}
+	input := response.Data.Components[0].Components[0]
+	if input.CustomID != CustomIDTextInputNote || input.Value != "late" {
+		t.Errorf("expected the text input pre-filled with the current note, got %+v", input)
+	}
+}
+
+func TestHandleSubmitNote(t *testing.T) {
+	signer := discordtest.NewSigner()
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	addGame2 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game2")
+	message := discordtest.EventMessage("Event", addGame1, addGame2)
+
+	response := submitNote(t, signer, "late", testUserID, message)
+	if response.Type != 4 || !strings.Contains(response.Data.Content, "Select a game first") {
+		t.Fatalf("expected a reply to select a game first, got %+v", response)
+	}
+
+	message = updatedMessage(t, message, press(t, signer, addGame1, testUserID, message))
+	message = updatedMessage(t, message, submitNote(t, signer, "  joining\nlate  ", testUserID, message))
+	// the note is kept for further games
+	message = updatedMessage(t, message, press(t, signer, addGame2, testUserID, message))
+
+	expected := "<@" + testUserID + ">: joining late"
+	for _, field := range message.Embeds[1].Fields {
+		if field.Value != expected {
+			t.Errorf("expected field value %q for %v, got %q", expected, field.Name, field.Value)
+		}
+	}
+
+	// an empty note removes the note
+	message = updatedMessage(t, message, submitNote(t, signer, "", testUserID, message))
+	if value := message.Embeds[1].Fields[0].Value; value != "<@"+testUserID+">" {
+		t.Errorf("expected the note to be removed, got %q", value)
+	}
+}
+
+func TestHandleSubmitTooLongNote(t *testing.T) {
+	signer := discordtest.NewSigner()
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	message := discordtest.EventMessage("Event", addGame1)
+	message = updatedMessage(t, message, press(t, signer, addGame1, testUserID, message))
+
+	message = updatedMessage(t, message, submitNote(t, signer, strings.Repeat("ä", maxNoteLength+20), testUserID, message))
+	expected := "<@" + testUserID + ">: " + strings.Repeat("ä", maxNoteLength)
+	if value := message.Embeds[1].Fields[0].Value; value != expected {
+		t.Errorf("expected the note to be shortened to %v characters, got %q", maxNoteLength, value)
+	}
+}
+
+func TestNotesInFullField(t *testing.T) {
+	signer := discordtest.NewSigner()
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	message := discordtest.EventMessage("Event", addGame1)
+	note := strings.Repeat("n", maxNoteLength)
+
+	// each attendee with a note takes 124 characters, so 8 of them and another attendee without a note fill the field
+	userIDs := []string{}
+	for i := 0; i < 9; i++ {
+		userID := fmt.Sprintf("84660000000000010%v", i)
+		userIDs = append(userIDs, userID)
+		message = updatedMessage(t, message, press(t, signer, addGame1, userID, message))
+	}
+	for _, userID := range userIDs[:8] {
+		message = updatedMessage(t, message, submitNote(t, signer, note, userID, message))
+	}
+
+	response := submitNote(t, signer, note, userIDs[8], message)
+	if response.Type != 4 || response.Data.Content != "The note does not fit into the list for Game1, please use a shorter note." {
+		t.Errorf("expected the note to be rejected, got %+v", response)
+	}
+	message = updatedMessage(t, message, submitNote(t, signer, "short", userIDs[8], message))
+	if value := message.Embeds[1].Fields[0].Value; !strings.HasSuffix(value, "<@"+userIDs[8]+">: short") || len(value) > maxFieldValueLength {
+		t.Errorf("expected the short note within %v characters, got %q", maxFieldValueLength, value)
+	}
+}
+
+func TestSignUpToFullField(t *testing.T) {
+	signer := discordtest.NewSigner()
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	addGame2 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game2")
+	message := discordtest.EventMessage("Event", addGame1, addGame2)
+	for i := 0; i < 8; i++ {
+		userID := fmt.Sprintf("84660000000000010%v", i)
+		message = updatedMessage(t, message, press(t, signer, addGame1, userID, message))
+		message = updatedMessage(t, message, submitNote(t, signer, strings.Repeat("n", maxNoteLength), userID, message))
+	}
+
+	// a note for another game, that does not fit into the field, is not shown for the game
+	message = updatedMessage(t, message, press(t, signer, addGame2, testUserID, message))
+	message = updatedMessage(t, message, submitNote(t, signer, "joining late at 21:00", testUserID, message))
+	message = updatedMessage(t, message, press(t, signer, addGame1, testUserID, message))
+	value := findGameField(message.Embeds[1], "Game1").Value
+	if !strings.HasSuffix(value, "\n<@"+testUserID+">") {
+		t.Errorf("expected the attendee without the note, got %q", value)
+	}
+	if value := findGameField(message.Embeds[1], "Game2").Value; value != "<@"+testUserID+">: joining late at 21:00" {
+		t.Errorf("expected the note for Game2, got %q", value)
+	}
+
+	// once no attendee fits anymore, further members are rejected
+	response := press(t, signer, addGame1, otherTestUserID, message)
+	if response.Type != 4 || response.Data.Content != "The list of attendees for Game1 is full." {
+		t.Errorf("expected the sign-up to be rejected, got %+v", response)
+	}
+}
@@ -1,42 +1,141 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"strings"
+	"runtime/debug"
+	"strconv"
+	"time"
 
-	"github.com/bsdlp/discord-interactions-go/interactions"
 	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/i18n"
 )
 
 const CustomIDButtonAddUserToGame = "add_user_to_game"
 const CustomIDButtonRemoveUserFromEvent = "remove_user_from_event"
+const CustomIDButtonAddNote = "add_note"
+const CustomIDModalSubmitNote = "submit_note"
+const CustomIDTextInputNote = "note"
+const CustomIDButtonLockEvent = "lock_event"
+const CustomIDButtonUnlockEvent = "unlock_event"
+const CustomIDButtonCancelEvent = "cancel_event"
+const CustomIDSelectKickAttendee = "kick_attendee"
 
-type InteractionHandler func(w http.ResponseWriter, interaction discord.ButtonInteraction, argument string)
+// InteractionHandler handles a single interaction and returns how the router should answer it.
+type InteractionHandler func(interaction discord.Interaction, argument string) InteractionResponse
+
+// InteractionResponse describes the answer to an interaction.
+// If all fields are empty, the interaction is acknowledged without any visible change.
+type InteractionResponse struct {
+	// Update replaces the message the used component is attached to (response type 7).
+	Update *discord.WebhookWithComponent
+	// Ephemeral is a text that is only visible to the user that triggered the interaction.
+	// If Update is set, the text is sent as follow-up message, otherwise it is the reply itself (response type 4).
+	Ephemeral string
+	// Modal opens a dialog for the user (response type 9) and can not be combined with the other fields.
+	Modal *discord.Modal
+}
+
+// UpdateMessage returns a response that replaces the message of the interaction.
+func UpdateMessage(message discord.WebhookWithComponent) InteractionResponse {
+	return InteractionResponse{
+		Update: &message,
+	}
+}
+
+// EphemeralReply returns a response that only shows the given text to the user and leaves the message as is.
+func EphemeralReply(text string) InteractionResponse {
+	return InteractionResponse{
+		Ephemeral: text,
+	}
+}
+
+// DeferredInteractionHandler is a handler that may take longer than the 3 seconds Discord waits for a response.
+// The context is cancelled, once the result of the handler can not be applied anymore.
+type DeferredInteractionHandler func(ctx context.Context, interaction discord.Interaction, argument string) InteractionResponse
+
+// deferredHandlerTimeout is the maximum duration of a deferred handler.
+// Interaction tokens are valid for 15 minutes, after that the original message can not be edited anymore.
+const deferredHandlerTimeout = 14 * time.Minute
+
+// registeredHandler is a handler for a custom_id, already wrapped with its own middlewares
+type registeredHandler struct {
+	handler InteractionHandler
+	// deferred is set instead of handler for deferred handlers, which are executed asynchronously
+	deferred func(ctx context.Context) InteractionHandler
+}
 
 type InteractionRouter struct {
-	customIDHandlerMapping map[string]InteractionHandler
+	customIDHandlerMapping map[string]registeredHandler
+	// middlewares are applied to the handlers of all custom_ids
+	middlewares []Middleware
+	// client is used for follow-up messages and edits of deferred responses
+	client *discord.Client
+	// deferredTimeout is the maximum duration of deferred handlers, see deferredHandlerTimeout
+	deferredTimeout time.Duration
+	// messageLocks serialises the responses with other changes of the messages
+	messageLocks *MessageLocks
+	// customIDs decodes the custom_ids of the interactions
+	customIDs CustomIDCodec
 }
 
-func NewInteractionRouter() InteractionRouter {
+// NewInteractionRouter creates a router, which uses the client for requests after the initial response.
+// The custom_ids of the interactions are decoded with the codec, that encoded the components of the messages.
+func NewInteractionRouter(client *discord.Client, customIDs CustomIDCodec) InteractionRouter {
 	return InteractionRouter{
-		customIDHandlerMapping: make(map[string]InteractionHandler),
+		customIDs:              customIDs,
+		customIDHandlerMapping: make(map[string]registeredHandler),
+		client:                 client,
+		deferredTimeout:        deferredHandlerTimeout,
+		messageLocks:           NewMessageLocks(),
 	}
 }
 
-func (i *InteractionRouter) RegisterHandler(customID string, handler InteractionHandler) {
-	i.customIDHandlerMapping[customID] = handler
+// MessageLocks returns the locks that must be held while changing a message outside of an interaction response.
+func (i *InteractionRouter) MessageLocks() *MessageLocks {
+	return i.messageLocks
+}
+
+// Use adds middlewares that are applied to the handlers of all custom_ids.
+// The first middleware is the outermost one, i.e. it is called first.
+func (i *InteractionRouter) Use(middlewares ...Middleware) {
+	i.middlewares = append(i.middlewares, middlewares...)
 }
 
-func (i *InteractionRouter) InteractionEndpoint(discordPubkey []byte) http.Handler {
-	return discord.Verify(discordPubkey, http.HandlerFunc(i.interactionEndpointInternal))
+// RegisterHandler registers the handler for a custom_id.
+// The middlewares are only applied to this handler and are called after the ones added via Use.
+func (i *InteractionRouter) RegisterHandler(customID string, handler InteractionHandler, middlewares ...Middleware) {
+	i.customIDHandlerMapping[customID] = registeredHandler{
+		handler: chain(middlewares, handler),
+	}
+}
+
+// RegisterDeferredHandler registers a handler that is allowed to take longer than the 3 seconds Discord waits for a response.
+// The interaction is acknowledged immediately (response type 6) and the original message is edited,
+// once the handler returns. Deferred handlers can not open modals.
+// The context of the handler is cancelled after deferredHandlerTimeout and the result of the handler is discarded.
+func (i *InteractionRouter) RegisterDeferredHandler(customID string, handler DeferredInteractionHandler, middlewares ...Middleware) {
+	i.customIDHandlerMapping[customID] = registeredHandler{
+		deferred: func(ctx context.Context) InteractionHandler {
+			return chain(middlewares, func(interaction discord.Interaction, argument string) InteractionResponse {
+				return handler(ctx, interaction, argument)
+			})
+		},
+	}
+}
+
+// InteractionEndpoint returns the handler for all requests of Discord, which are checked by the verifier first.
+func (i *InteractionRouter) InteractionEndpoint(verifier *discord.Verifier) http.Handler {
+	return verifier.Verify(http.HandlerFunc(i.interactionEndpointInternal))
 }
 
 func (i *InteractionRouter) interactionEndpointInternal(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
-	var interaction discord.ButtonInteraction
+	var interaction discord.Interaction
 	err := json.NewDecoder(r.Body).Decode(&interaction)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -44,36 +143,211 @@ func (i *InteractionRouter) interactionEndpointInternal(w http.ResponseWriter, r
 		return
 	}
 
-	if interaction.Type == 1 {
-		err = writeJSON(w, interactions.Data{
-			Type: 1,
+	switch interaction.Type {
+	case discord.InteractionTypePing:
+		err = writeJSON(w, discord.InteractionResponse{
+			Type: discord.InteractionResponsePong,
 		})
 		if err != nil {
 			fmt.Printf("error on sending pong as HTTP-Response: %v\n", err)
 		}
-	} else {
+	case discord.InteractionTypeMessageComponent, discord.InteractionTypeModalSubmit:
+		// message components and modal submits are both routed via their custom_id
+		if interaction.Data == nil || interaction.Message == nil || interaction.Member == nil {
+			// Note: all handlers work on the event message and only guild channels have event messages
+			fmt.Printf("interaction %v without data, message or member is not supported\n", interaction.ID)
+			i.writeInteractionResponse(w, interaction, EphemeralReply(i18n.Translate(interaction.Locale, "This interaction is not supported.")))
+			return
+		}
 		i.interactionHandler(w, interaction)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Printf("unsupported interaction type %v\n", interaction.Type)
 	}
 }
 
-func (i *InteractionRouter) interactionHandler(w http.ResponseWriter, interaction discord.ButtonInteraction) {
-	customID := interaction.DataInternal.CustomID
-	// parse custom id as "command_with_underscores After the first whitespace, free text follows"
-	splitted := strings.SplitN(customID, " ", 2)
-	customID = splitted[0]
-	argument := ""
-	if len(splitted) > 1 {
-		argument = splitted[1]
+// kind describes the type of the response for logging.
+func (r InteractionResponse) kind() string {
+	switch {
+	case r.Modal != nil:
+		return "modal"
+	case r.Update != nil && r.Ephemeral != "":
+		return "update+ephemeral"
+	case r.Update != nil:
+		return "update"
+	case r.Ephemeral != "":
+		return "ephemeral"
+	default:
+		return "acknowledge"
+	}
+}
+
+func (i *InteractionRouter) interactionHandler(w http.ResponseWriter, interaction discord.Interaction) {
+	decoded, err := i.customIDs.Decode(interaction.Data.CustomID)
+	if err != nil {
+		fmt.Printf("could not decode custom_id: %v\n", err)
+		i.writeInteractionResponse(w, interaction, EphemeralReply(i18n.Translate(interaction.Locale, "This message is outdated and can not be used anymore.")))
+		return
 	}
-	handler, ok := i.customIDHandlerMapping[customID]
+	customID := decoded.Action
+	argument := decoded.Argument()
+	registered, ok := i.customIDHandlerMapping[customID]
 	if !ok {
 		fmt.Printf("unknown custom_id: %v\n", customID)
 		return
 	}
-	handler(w, interaction, argument)
+	if registered.deferred != nil {
+		// acknowledge the interaction, so that the handler is not bound to the 3 second window
+		i.writeInteractionResponse(w, interaction, InteractionResponse{})
+		// Note: the original message can only be edited after Discord received the acknowledgement
+		flush(w)
+		go i.runDeferredHandler(interaction, customID, argument, registered.deferred)
+		return
+	}
+	unlock := i.messageLocks.Lock(interaction.Message.ID)
+	defer unlock()
+	if i.messageLocks.isOutdated(interaction.Message.ID, interaction.Message.EditedTimestamp) {
+		// Note: the response would revert the change that was made after Discord sent the interaction
+		i.writeInteractionResponse(w, interaction, EphemeralReply(i18n.Translate(interaction.Locale, outdatedMessageReply)))
+		return
+	}
+	handler := chain(i.middlewares, registered.handler)
+	i.writeInteractionResponse(w, interaction, handler(interaction, argument))
+	// Note: the lock is held until the response was sent to Discord
+	flush(w)
+}
+
+const outdatedMessageReply = "The message was just updated, please try again."
+
+func (i *InteractionRouter) runDeferredHandler(interaction discord.Interaction, customID, argument string, deferred func(ctx context.Context) InteractionHandler) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), i.deferredTimeout)
+	defer cancel()
+	handler := chain(i.middlewares, deferred(ctx))
+	// buffered, so that a handler that finishes after the timeout does not block forever
+	result := make(chan InteractionResponse, 1)
+	go func() {
+		// Note: a panic in this goroutine would crash the process, even if the Recover middleware is not registered
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				fmt.Printf("recovered from panic in deferred handler for custom_id %v: %v\n%s\n", customID, recovered, debug.Stack())
+				result <- EphemeralReply(i18n.Translate(interaction.Locale, panicReply))
+			}
+		}()
+		result <- handler(interaction, argument)
+	}()
+
+	select {
+	case response := <-result:
+		fmt.Printf("deferred handler for custom_id %v finished after %v\n", customID, time.Since(start))
+		i.applyDeferredResponse(interaction, response)
+	case <-ctx.Done():
+		fmt.Printf("deferred handler for custom_id %v was cancelled after %v, its result is discarded\n", customID, time.Since(start))
+	}
+}
+
+func (i *InteractionRouter) applyDeferredResponse(interaction discord.Interaction, response InteractionResponse) {
+	if response.Modal != nil {
+		fmt.Printf("deferred handler for custom_id %v returned a modal, which is not supported\n", interaction.Data.CustomID)
+		return
+	}
+	if response.Update != nil {
+		unlock := i.messageLocks.Lock(interaction.Message.ID)
+		defer unlock()
+		if i.messageLocks.isOutdated(interaction.Message.ID, interaction.Message.EditedTimestamp) {
+			fmt.Printf("discarding the result of the deferred handler for custom_id %v, since the message was changed in the meantime\n", interaction.Data.CustomID)
+			i.sendEphemeralFollowup(interaction, i18n.Translate(interaction.Locale, outdatedMessageReply))
+			return
+		}
+		err := retryOnRateLimit(func() error {
+			_, err := discord.EditWebhookMessage(i.client, interaction.ApplicationID, interaction.Token, "@original", *response.Update)
+			return err
+		})
+		if err != nil {
+			fmt.Printf("could not edit original message of deferred interaction: %v\n", err)
+		}
+	}
+	if response.Ephemeral != "" {
+		i.sendEphemeralFollowup(interaction, response.Ephemeral)
+	}
+}
+
+func (i *InteractionRouter) writeInteractionResponse(w http.ResponseWriter, interaction discord.Interaction, response InteractionResponse) {
+	var err error
+	switch {
+	case response.Modal != nil:
+		err = writeJSON(w, discord.NewModalResponse(*response.Modal))
+	case response.Update != nil:
+		err = writeJSON(w, discord.NewMessageResponse(discord.InteractionResponseUpdateMessage, *response.Update))
+		if err == nil && response.Ephemeral != "" {
+			// Note: Discord only accepts follow-up messages after the initial response was received,
+			// so the complete response is sent before the follow-up is started
+			flush(w)
+			go i.sendEphemeralFollowup(interaction, response.Ephemeral)
+		}
+	case response.Ephemeral != "":
+		err = writeJSON(w, discord.NewMessageResponse(discord.InteractionResponseChannelMessage, ephemeralMessage(response.Ephemeral)))
+	default:
+		// acknowledge the interaction without changing the message
+		err = writeJSON(w, discord.InteractionResponse{
+			Type: discord.InteractionResponseDeferredUpdateMessage,
+		})
+	}
+	if err != nil {
+		fmt.Printf("could not write interaction response: %v\n", err)
+	}
 }
 
+func (i *InteractionRouter) sendEphemeralFollowup(interaction discord.Interaction, text string) {
+	err := retryOnRateLimit(func() error {
+		return discord.SendFollowupMessage(i.client, interaction.ApplicationID, interaction.Token, ephemeralMessage(text))
+	})
+	if err != nil {
+		fmt.Printf("could not send follow-up message: %v\n", err)
+	}
+}
+
+// maxRateLimitRetries is the number of retries for requests with an interaction token, that hit a rate limit.
+// Unlike scheduled messages, they are not queued, since the interaction token expires after 15 minutes.
+const maxRateLimitRetries = 3
+
+// retryOnRateLimit calls request again after the rate limit reset, if it failed because of a rate limit.
+func retryOnRateLimit(request func() error) error {
+	err := request()
+	for retry := 0; retry < maxRateLimitRetries; retry++ {
+		var rateLimitError *discord.RateLimitError
+		if !errors.As(err, &rateLimitError) {
+			return err
+		}
+		time.Sleep(rateLimitError.RetryAfter)
+		err = request()
+	}
+	return err
+}
+
+func ephemeralMessage(text string) discord.WebhookWithComponent {
+	message := discord.WebhookWithComponent{
+		Flags: discord.MessageFlagEphemeral,
+	}
+	message.Content = text
+	return message
+}
+
+// flush sends the response written so far to the client.
+func flush(w http.ResponseWriter) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// writeJSON writes the data with its Content-Length, so that the response is complete once it is flushed.
 func writeJSON(w http.ResponseWriter, data interface{}) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
 	w.Header().Set("Content-Type", "application/json")
-	return json.NewEncoder(w).Encode(data)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	_, err = w.Write(body)
+	return err
 }
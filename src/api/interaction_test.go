@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/discordtest"
+)
+
+// newTestEndpoint creates the interaction endpoint with the handlers for attendees and organisers, like in main.
+// Follow-up messages are sent to a fake server, which is closed at the end of the test.
+func newTestEndpoint(t *testing.T, signer *discordtest.Signer) http.Handler {
+	_, endpoint := newTestRouter(t, signer, EventMessages{})
+	return endpoint
+}
+
+// newTestRouter creates a router with the handlers of the event messages and its endpoint for the signer.
+func newTestRouter(t *testing.T, signer *discordtest.Signer, messages EventMessages) (*InteractionRouter, http.Handler) {
+	fake := discordtest.NewFakeDiscord()
+	t.Cleanup(fake.Close)
+	router := NewInteractionRouter(discord.NewClient(fake.BaseURL(), "DiscordBot (https://example.org, 1)"), NewCustomIDCodec())
+	router.Use(Recover())
+	router.RegisterHandler(CustomIDButtonAddUserToGame, messages.HandleAddUserToGame)
+	router.RegisterHandler(CustomIDButtonRemoveUserFromEvent, messages.HandleRemoveUserFromEvent)
+	router.RegisterHandler(CustomIDButtonAddNote, messages.HandleShowNoteModal)
+	router.RegisterHandler(CustomIDModalSubmitNote, messages.HandleSubmitNote)
+	router.RegisterHandler(CustomIDButtonLockEvent, messages.HandleLockEvent)
+	router.RegisterHandler(CustomIDButtonUnlockEvent, messages.HandleUnlockEvent)
+	router.RegisterHandler(CustomIDButtonCancelEvent, messages.HandleCancelEvent)
+	router.RegisterHandler(CustomIDSelectKickAttendee, messages.HandleKickAttendee)
+	return &router, fake.InteractionEndpoint(router.InteractionEndpoint(discord.NewVerifier(signer.PublicKey, discord.DefaultMaxTimestampSkew)))
+}
+
+func TestPing(t *testing.T) {
+	signer := discordtest.NewSigner()
+	endpoint := newTestEndpoint(t, signer)
+
+	recorder := signer.Send(endpoint, discordtest.PingInteraction())
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status code %v, got %v", http.StatusOK, recorder.Code)
+	}
+	response, err := discordtest.DecodeResponse(recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Type != 1 {
+		t.Errorf("expected pong (type 1), got type %v", response.Type)
+	}
+}
+
+func TestRejectInvalidSignature(t *testing.T) {
+	signer := discordtest.NewSigner()
+	endpoint := newTestEndpoint(t, signer)
+
+	// sign with a different key than the one known to the endpoint
+	otherSigner := discordtest.NewSigner()
+	recorder := otherSigner.Send(endpoint, discordtest.PingInteraction())
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected status code %v, got %v", http.StatusUnauthorized, recorder.Code)
+	}
+}
+
+func TestRejectOutdatedTimestamp(t *testing.T) {
+	signer := discordtest.NewSigner()
+	endpoint := newTestEndpoint(t, signer)
+
+	body := discordtest.Marshal(discordtest.PingInteraction())
+	request := signer.NewRequest(body, time.Now().Add(-discord.DefaultMaxTimestampSkew-time.Minute))
+	recorder := discordtest.SendRequest(endpoint, request)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected status code %v, got %v", http.StatusUnauthorized, recorder.Code)
+	}
+}
+
+func TestRejectReplayedInteraction(t *testing.T) {
+	signer := discordtest.NewSigner()
+	endpoint := newTestEndpoint(t, signer)
+
+	body := discordtest.Marshal(discordtest.PingInteraction())
+	now := time.Now()
+	first := discordtest.SendRequest(endpoint, signer.NewRequest(body, now))
+	if first.Code != http.StatusOK {
+		t.Fatalf("expected status code %v for first request, got %v", http.StatusOK, first.Code)
+	}
+	replayed := discordtest.SendRequest(endpoint, signer.NewRequest(body, now))
+	if replayed.Code != http.StatusUnauthorized {
+		t.Errorf("expected status code %v for replayed request, got %v", http.StatusUnauthorized, replayed.Code)
+	}
+}
+
+func TestEphemeralFollowupAfterResponse(t *testing.T) {
+	fake := discordtest.NewFakeDiscord()
+	defer fake.Close()
+	signer := discordtest.NewSigner()
+	router := NewInteractionRouter(discord.NewClient(fake.BaseURL(), "DiscordBot (https://example.org, 1)"), NewCustomIDCodec())
+	router.RegisterHandler("update-and-reply", func(interaction discord.Interaction, argument string) InteractionResponse {
+		return InteractionResponse{
+			Update:    &interaction.Message.WebhookWithComponent,
+			Ephemeral: "Only for you",
+		}
+	})
+	endpoint := fake.InteractionEndpoint(router.InteractionEndpoint(discord.NewVerifier(signer.PublicKey, discord.DefaultMaxTimestampSkew)))
+
+	interaction := discordtest.ButtonInteraction("update-and-reply", testUserID, discordtest.EventMessage("Event", "update-and-reply"))
+	recorder := signer.Send(endpoint, interaction)
+	response, err := discordtest.DecodeResponse(recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Type != discord.InteractionResponseUpdateMessage {
+		t.Errorf("expected response type %v, got %v", discord.InteractionResponseUpdateMessage, response.Type)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(fake.Followups()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	followups := fake.Followups()
+	if len(followups) != 1 {
+		t.Fatalf("expected one follow-up message, got %v (requests: %v)", len(followups), fake.Requests())
+	}
+	if followups[0].Content != "Only for you" || followups[0].Flags != discord.MessageFlagEphemeral {
+		t.Errorf("expected ephemeral follow-up message, got %+v", followups[0].WebhookWithComponent)
+	}
+	expected := []string{
+		"POST /interactions/" + interaction.ID + "/" + interaction.Token + "/callback",
+		"POST /webhooks/" + interaction.ApplicationID + "/" + interaction.Token,
+	}
+	if requests := fake.Requests(); !reflect.DeepEqual(requests, expected) {
+		t.Errorf("expected requests %v, got %v", expected, requests)
+	}
+}
+
+// newDeferredTestEndpoint creates an endpoint with the deferred handler for the custom_id "deferred".
+func newDeferredTestEndpoint(fake *discordtest.FakeDiscord, signer *discordtest.Signer, timeout time.Duration, handler DeferredInteractionHandler) http.Handler {
+	router := NewInteractionRouter(discord.NewClient(fake.BaseURL(), "DiscordBot (https://example.org, 1)"), NewCustomIDCodec())
+	router.deferredTimeout = timeout
+	router.RegisterDeferredHandler("deferred", handler)
+	return fake.InteractionEndpoint(router.InteractionEndpoint(discord.NewVerifier(signer.PublicKey, discord.DefaultMaxTimestampSkew)))
+}
+
+func TestDeferredHandlerEditsOriginalMessage(t *testing.T) {
+	fake := discordtest.NewFakeDiscord()
+	defer fake.Close()
+	signer := discordtest.NewSigner()
+	endpoint := newDeferredTestEndpoint(fake, signer, time.Minute, func(ctx context.Context, interaction discord.Interaction, argument string) InteractionResponse {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Errorf("expected the context of the handler to have a deadline")
+		}
+		// longer than Discord waits for the initial response in a real setup
+		time.Sleep(50 * time.Millisecond)
+		message := interaction.Message.WebhookWithComponent
+		message.Embeds[0].Title = "Updated Event"
+		return InteractionResponse{
+			Update:    &message,
+			Ephemeral: "Done",
+		}
+	})
+
+	interaction := discordtest.ButtonInteraction("deferred", testUserID, discordtest.EventMessage("Event", "deferred"))
+	response, err := discordtest.DecodeResponse(signer.Send(endpoint, interaction))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Type != discord.InteractionResponseDeferredUpdateMessage {
+		t.Errorf("expected the interaction to be acknowledged (type 6), got type %v", response.Type)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(fake.Followups()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	original, ok := fake.OriginalMessage(interaction.Token)
+	if !ok || original.Embeds[0].Title != "Updated Event" {
+		t.Errorf("expected the original message to be edited, got %+v", original)
+	}
+	expected := []string{
+		"POST /interactions/" + interaction.ID + "/" + interaction.Token + "/callback",
+		"PATCH /webhooks/" + interaction.ApplicationID + "/" + interaction.Token + "/messages/@original",
+		"POST /webhooks/" + interaction.ApplicationID + "/" + interaction.Token,
+	}
+	if requests := fake.Requests(); !reflect.DeepEqual(requests, expected) {
+		t.Errorf("expected requests %v, got %v", expected, requests)
+	}
+}
+
+func TestDeferredHandlerRecoversFromPanic(t *testing.T) {
+	fake := discordtest.NewFakeDiscord()
+	defer fake.Close()
+	signer := discordtest.NewSigner()
+	// Note: without the Recover middleware
+	endpoint := newDeferredTestEndpoint(fake, signer, time.Minute, func(ctx context.Context, interaction discord.Interaction, argument string) InteractionResponse {
+		panic("test")
+	})
+
+	interaction := discordtest.ButtonInteraction("deferred", testUserID, discordtest.EventMessage("Event", "deferred"))
+	signer.Send(endpoint, interaction)
+
+	deadline := time.Now().Add(time.Second)
+	for len(fake.Followups()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	followups := fake.Followups()
+	if len(followups) != 1 || followups[0].Content != panicReply || followups[0].Flags != discord.MessageFlagEphemeral {
+		t.Errorf("expected an ephemeral follow-up message about the error, got %+v", followups)
+	}
+}
+
+func TestDeferredHandlerIsCancelledAfterTimeout(t *testing.T) {
+	fake := discordtest.NewFakeDiscord()
+	defer fake.Close()
+	signer := discordtest.NewSigner()
+	cancelled := make(chan struct{})
+	endpoint := newDeferredTestEndpoint(fake, signer, 20*time.Millisecond, func(ctx context.Context, interaction discord.Interaction, argument string) InteractionResponse {
+		<-ctx.Done()
+		close(cancelled)
+		return UpdateMessage(interaction.Message.WebhookWithComponent)
+	})
+
+	interaction := discordtest.ButtonInteraction("deferred", testUserID, discordtest.EventMessage("Event", "deferred"))
+	signer.Send(endpoint, interaction)
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the context of the handler to be cancelled")
+	}
+	// the result of the handler must not be applied
+	time.Sleep(20 * time.Millisecond)
+	expected := []string{"POST /interactions/" + interaction.ID + "/" + interaction.Token + "/callback"}
+	if requests := fake.Requests(); !reflect.DeepEqual(requests, expected) {
+		t.Errorf("expected requests %v, got %v", expected, requests)
+	}
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/localthomas/discord-rsvp/i18n"
+)
+
+// EventMessages changes the event messages according to the configuration of the games and the layout.
+// Its handlers are registered at an InteractionRouter. The zero value uses the default layouts and games without limit.
+type EventMessages struct {
+	AttendeeLayout  AttendeeLayout
+	OrganiserLayout OrganiserLayout
+	// GameCapacities maps the titles of the games to their maximum number of attendees.
+	// Users that sign up for a full game are put on its waitlist. Games without limit are missing.
+	GameCapacities map[string]int
+}
+
+// AttendeeLayout defines the appearance of the embed that lists the attendees of an event message.
+type AttendeeLayout struct {
+	// Title returns the title of the embed in the locale of the message for the number of distinct attendees
+	Title func(locale string, attendees int) string
+	Color int
+}
+
+// DefaultAttendeeLayout is used, unless another layout is set in EventMessages.
+var DefaultAttendeeLayout = AttendeeLayout{
+	Title: func(locale string, attendees int) string {
+		return i18n.Translate(locale, "Attendees")
+	},
+	Color: 0x3ba55d,
+}
+
+// applyAttendeeLayout sets the title and the colour of the embed for the attendees.
+func (m EventMessages) applyAttendeeLayout(embed *discordgo.MessageEmbed, locale string) {
+	layout := m.AttendeeLayout
+	if layout.Title == nil {
+		layout = DefaultAttendeeLayout
+	}
+
+	seen := make(map[string]bool)
+	for _, field := range embed.Fields {
+		for _, user := range stringToAttendeeList(field.Value) {
+			seen[user.UserID] = true
+		}
+	}
+	embed.Title = layout.Title(locale, len(seen))
+	embed.Color = layout.Color
+}
+
+// OrganiserLayout defines the labels of the buttons for organisers, that are switched by the handlers.
+type OrganiserLayout struct {
+	// LockLabel and UnlockLabel return the label of the button for locking and unlocking the RSVP in the locale of the message
+	LockLabel   func(locale string) string
+	UnlockLabel func(locale string) string
+}
+
+// DefaultOrganiserLayout is used, unless another layout is set in EventMessages.
+var DefaultOrganiserLayout = OrganiserLayout{
+	LockLabel: func(locale string) string {
+		return i18n.Translate(locale, "Lock RSVP")
+	},
+	UnlockLabel: func(locale string) string {
+		return i18n.Translate(locale, "Unlock RSVP")
+	},
+}
+
+// organiserLayout returns the layout of the buttons for organisers or the default layout, if none is set.
+func (m EventMessages) organiserLayout() OrganiserLayout {
+	if m.OrganiserLayout.LockLabel == nil || m.OrganiserLayout.UnlockLabel == nil {
+		return DefaultOrganiserLayout
+	}
+	return m.OrganiserLayout
+}
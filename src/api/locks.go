@@ -0,0 +1,74 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// MessageLocks serialises the changes of event messages by interaction responses and by the scheduler.
+// Both replace the complete message with a changed copy of the version they know, so without the lock,
+// a change made in between would be overwritten.
+type MessageLocks struct {
+	mutex    sync.Mutex
+	messages map[string]*messageLock
+}
+
+type messageLock struct {
+	sync.Mutex
+	// edited is the edited_timestamp of the last change via SetEdited
+	edited time.Time
+}
+
+// NewMessageLocks creates the locks for the event messages, which are shared by the router and the scheduler.
+func NewMessageLocks() *MessageLocks {
+	return &MessageLocks{
+		messages: make(map[string]*messageLock),
+	}
+}
+
+// Lock blocks until no other change of the message is in progress and returns the function that releases the lock.
+func (l *MessageLocks) Lock(messageID string) func() {
+	lock := l.message(messageID)
+	lock.Lock()
+	return lock.Unlock
+}
+
+// SetEdited records the edited_timestamp returned by Discord for a change of the message, that was not made
+// by an interaction response. The caller must hold the lock of the message.
+func (l *MessageLocks) SetEdited(messageID, editedTimestamp string) {
+	edited, err := time.Parse(time.RFC3339Nano, editedTimestamp)
+	if err != nil {
+		return
+	}
+	l.message(messageID).edited = edited
+}
+
+// Forget removes the lock of a message that was deleted.
+func (l *MessageLocks) Forget(messageID string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	delete(l.messages, messageID)
+}
+
+// isOutdated reports if the version of the message with the edited_timestamp, e.g. the message of an interaction,
+// is older than the last change recorded via SetEdited. The caller must hold the lock of the message.
+func (l *MessageLocks) isOutdated(messageID, editedTimestamp string) bool {
+	lastChange := l.message(messageID).edited
+	if lastChange.IsZero() {
+		return false
+	}
+	edited, err := time.Parse(time.RFC3339Nano, editedTimestamp)
+	// Note: messages that were never edited have no edited_timestamp
+	return err != nil || edited.Before(lastChange)
+}
+
+func (l *MessageLocks) message(messageID string) *messageLock {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	lock, ok := l.messages[messageID]
+	if !ok {
+		lock = &messageLock{}
+		l.messages[messageID] = lock
+	}
+	return lock
+}
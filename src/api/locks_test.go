@@ -0,0 +1,72 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/discordtest"
+)
+
+func TestInteractionsWaitForMessageLock(t *testing.T) {
+	signer := discordtest.NewSigner()
+	router, endpoint := newTestRouter(t, signer, EventMessages{})
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	message := discordtest.EventMessage("Event", addGame1)
+
+	unlock := router.MessageLocks().Lock(message.ID)
+	responses := make(chan discord.InteractionResponse)
+	go func() {
+		response, err := discordtest.DecodeResponse(signer.Send(endpoint, discordtest.ButtonInteraction(addGame1, testUserID, message)))
+		if err != nil {
+			t.Error(err)
+		}
+		responses <- response
+	}()
+
+	select {
+	case response := <-responses:
+		t.Fatalf("expected the interaction to wait for the lock, got %+v", response)
+	case <-time.After(50 * time.Millisecond):
+	}
+	// the change of the message is not made by an interaction
+	router.MessageLocks().SetEdited(message.ID, time.Now().UTC().Format(time.RFC3339Nano))
+	unlock()
+
+	// the interaction was sent for the previous version of the message, so its response would revert the change
+	response := <-responses
+	if response.Type != 4 || response.Data.Content != "The message was just updated, please try again." {
+		t.Errorf("expected the interaction to be rejected, got %+v", response)
+	}
+
+	// interactions for the current version of the message are handled
+	message.EditedTimestamp = time.Now().UTC().Format(time.RFC3339Nano)
+	response, err := discordtest.DecodeResponse(signer.Send(endpoint, discordtest.ButtonInteraction(addGame1, testUserID, message)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Type != discord.InteractionResponseUpdateMessage {
+		t.Errorf("expected message update (type 7), got %+v", response)
+	}
+}
+
+func TestMessageLocksWithoutEdit(t *testing.T) {
+	locks := NewMessageLocks()
+	unlock := locks.Lock("1")
+	if locks.isOutdated("1", "") {
+		t.Errorf("expected messages without recorded edit to be up to date")
+	}
+	locks.SetEdited("1", "2021-06-20T12:00:00.123456+00:00")
+	if !locks.isOutdated("1", "") || !locks.isOutdated("1", "2021-06-20T12:00:00+00:00") {
+		t.Errorf("expected older versions of the message to be outdated")
+	}
+	if locks.isOutdated("1", "2021-06-20T12:00:00.123456+00:00") || locks.isOutdated("2", "") {
+		t.Errorf("expected the recorded and other messages to be up to date")
+	}
+	unlock()
+
+	locks.Forget("1")
+	if locks.isOutdated("1", "") {
+		t.Errorf("expected the recorded edit to be forgotten")
+	}
+}
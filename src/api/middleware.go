@@ -0,0 +1,155 @@
+package api
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/i18n"
+)
+
+// Middleware wraps an InteractionHandler, e.g. to log interactions or to reject them before the handler is called.
+type Middleware func(next InteractionHandler) InteractionHandler
+
+// chain wraps the handler with the middlewares, so that the first middleware is called first.
+func chain(middlewares []Middleware, handler InteractionHandler) InteractionHandler {
+	for index := len(middlewares) - 1; index >= 0; index-- {
+		handler = middlewares[index](handler)
+	}
+	return handler
+}
+
+const panicReply = "Something went wrong, please try again later."
+
+// Recover returns a middleware that recovers from panics in the following handlers.
+// The panic is logged and the user is informed with an ephemeral message.
+func Recover() Middleware {
+	return func(next InteractionHandler) InteractionHandler {
+		return func(interaction discord.Interaction, argument string) (response InteractionResponse) {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					fmt.Printf("recovered from panic in handler for custom_id %v: %v\n%s\n", interaction.Data.CustomID, recovered, debug.Stack())
+					response = EphemeralReply(i18n.Translate(interaction.Locale, panicReply))
+				}
+			}()
+			return next(interaction, argument)
+		}
+	}
+}
+
+// Logging returns a middleware that logs every interaction as a single line of key=value pairs.
+func Logging() Middleware {
+	return func(next InteractionHandler) InteractionHandler {
+		return func(interaction discord.Interaction, argument string) InteractionResponse {
+			start := time.Now()
+			response := next(interaction, argument)
+			fmt.Printf("interaction id=%v custom_id=%q guild_id=%v user_id=%v response=%v duration=%v\n",
+				interaction.ID,
+				interaction.Data.CustomID,
+				interaction.GuildID,
+				interaction.Member.User.ID,
+				response.kind(),
+				time.Since(start))
+			return response
+		}
+	}
+}
+
+// Check returns a middleware that only calls the following handlers, if the check allows the interaction.
+// Otherwise the reason of the check is shown to the user.
+func Check(check InteractionCheck) Middleware {
+	return func(next InteractionHandler) InteractionHandler {
+		return func(interaction discord.Interaction, argument string) InteractionResponse {
+			if allowed, reason := check(interaction); !allowed {
+				return EphemeralReply(reason)
+			}
+			return next(interaction, argument)
+		}
+	}
+}
+
+// MessageLocale returns a middleware that sets the MessageLocale of the interaction to the locale returned by locale,
+// e.g. the configured locale of the event. The handlers use it for all texts of the event message,
+// while replies that are only visible to the user use the locale of the user.
+func MessageLocale(locale func(interaction discord.Interaction) string) Middleware {
+	return func(next InteractionHandler) InteractionHandler {
+		return func(interaction discord.Interaction, argument string) InteractionResponse {
+			interaction.MessageLocale = locale(interaction)
+			return next(interaction, argument)
+		}
+	}
+}
+
+// LatencyMetrics collects the number and duration of handled interactions per action of a custom_id.
+type LatencyMetrics struct {
+	mutex   sync.Mutex
+	actions map[string]*actionLatency
+}
+
+type actionLatency struct {
+	Count         uint64
+	TotalDuration time.Duration
+	MaxDuration   time.Duration
+}
+
+// NewLatencyMetrics creates empty metrics, which are filled by the middleware returned by Metrics.
+func NewLatencyMetrics() *LatencyMetrics {
+	return &LatencyMetrics{
+		actions: make(map[string]*actionLatency),
+	}
+}
+
+// Metrics returns a middleware that records the duration of the following handlers.
+func (m *LatencyMetrics) Metrics() Middleware {
+	return func(next InteractionHandler) InteractionHandler {
+		return func(interaction discord.Interaction, argument string) InteractionResponse {
+			start := time.Now()
+			response := next(interaction, argument)
+			m.record(customIDAction(interaction.Data.CustomID), time.Since(start))
+			return response
+		}
+	}
+}
+
+func (m *LatencyMetrics) record(action string, duration time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	latency, ok := m.actions[action]
+	if !ok {
+		latency = &actionLatency{}
+		m.actions[action] = latency
+	}
+	latency.Count++
+	latency.TotalDuration += duration
+	if duration > latency.MaxDuration {
+		latency.MaxDuration = duration
+	}
+}
+
+// ActionMetrics contains the collected metrics of one action, with durations in milliseconds.
+type ActionMetrics struct {
+	Count             uint64  `json:"count"`
+	AverageDurationMs float64 `json:"average_duration_ms"`
+	MaxDurationMs     float64 `json:"max_duration_ms"`
+}
+
+// Snapshot returns the current metrics per action.
+func (m *LatencyMetrics) Snapshot() map[string]ActionMetrics {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	snapshot := make(map[string]ActionMetrics, len(m.actions))
+	for action, latency := range m.actions {
+		snapshot[action] = ActionMetrics{
+			Count:             latency.Count,
+			AverageDurationMs: durationToMs(latency.TotalDuration) / float64(latency.Count),
+			MaxDurationMs:     durationToMs(latency.MaxDuration),
+		}
+	}
+	return snapshot
+}
+
+func durationToMs(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
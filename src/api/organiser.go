@@ -0,0 +1,302 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/i18n"
+)
+
+// The status of an event message is shown via texts at the start of its title or the end of its description,
+// which consist of a decoration and a translated text. The status is recognised in all locales, see trimStatusSuffix.
+// Since the title can be changed via templates, a cancelled event is recognised by the custom_id of its cancel button instead.
+const lockedReply = "The RSVP for this event is locked."
+const lockedDescriptionDecoration = "\n\n🔒 "
+const lockedDescriptionText = "The RSVP is locked."
+const cancelledTitleText = "Cancelled"
+const cancelledTitleDecoration = ": "
+const cancelledDescriptionDecoration = "\n\n❌ "
+const cancelledDescriptionText = "This event was cancelled."
+const cancelledColor = 0x99aab5
+const cancelledArgument = "cancelled"
+const closedReply = "The RSVP for this event is closed."
+const closedDescriptionDecoration = "\n\n"
+const closedDescriptionText = "RSVP closed."
+
+// maxActionRows is the maximum number of action rows a message can have
+const maxActionRows = 5
+
+// maxSelectOptions is the maximum number of options of a select menu
+const maxSelectOptions = 25
+
+// HandleLockEvent disables all components for attendees, so that the list of attendees can not be changed anymore.
+func (m EventMessages) HandleLockEvent(interaction discord.Interaction, argument string) InteractionResponse {
+	if isLocked(interaction.Message.Components) {
+		return EphemeralReply(i18n.Translate(interaction.Locale, lockedReply))
+	}
+	m.setLocked(&interaction.Message.WebhookWithComponent, messageLocale(interaction), true)
+	return UpdateMessage(interaction.Message.WebhookWithComponent)
+}
+
+// HandleUnlockEvent reverts HandleLockEvent.
+func (m EventMessages) HandleUnlockEvent(interaction discord.Interaction, argument string) InteractionResponse {
+	if !isLocked(interaction.Message.Components) {
+		return EphemeralReply(i18n.Translate(interaction.Locale, "The RSVP for this event is not locked."))
+	}
+	m.setLocked(&interaction.Message.WebhookWithComponent, messageLocale(interaction), false)
+	return UpdateMessage(interaction.Message.WebhookWithComponent)
+}
+
+// HandleCancelEvent marks the event as cancelled and disables all components.
+func (m EventMessages) HandleCancelEvent(interaction discord.Interaction, argument string) InteractionResponse {
+	m.CancelEventMessage(&interaction.Message.WebhookWithComponent, messageLocale(interaction))
+	return UpdateMessage(interaction.Message.WebhookWithComponent)
+}
+
+// HandleKickAttendee removes the user selected by an organiser from all games.
+func (m EventMessages) HandleKickAttendee(interaction discord.Interaction, argument string) InteractionResponse {
+	if len(interaction.Data.Values) == 0 {
+		return InteractionResponse{}
+	}
+	userID := interaction.Data.Values[0]
+	if !m.removeUserFromEvent(interaction.Message, userID, messageLocale(interaction)) {
+		return EphemeralReply(i18n.Sprintf(interaction.Locale, "%v is not signed up for any game.", userMention(userID)))
+	}
+	refreshAttendeeSelect(interaction.Message, messageLocale(interaction), nil)
+	response := UpdateMessage(interaction.Message.WebhookWithComponent)
+	response.Ephemeral = i18n.Sprintf(interaction.Locale, "%v was removed from the event.", userMention(userID))
+	return response
+}
+
+// CancelEventMessage marks the event of the message as cancelled and disables all of its components.
+// The texts that mark the message as cancelled are added in the given locale.
+func (m EventMessages) CancelEventMessage(message *discord.WebhookWithComponent, locale string) {
+	cancelled := isCancelled(*message)
+	if !isLocked(message.Components) {
+		m.setLocked(message, locale, true)
+	}
+	forEachComponent(message.Components, func(component *discord.Component) {
+		component.Disabled = true
+		if customIDAction(component.CustomID) == CustomIDButtonCancelEvent {
+			component.CustomID = EncodeCustomID(CustomIDButtonCancelEvent, cancelledArgument)
+		}
+	})
+	if len(message.Embeds) > 0 && !cancelled {
+		embed := message.Embeds[0]
+		embed.Title = i18n.Translate(locale, cancelledTitleText) + cancelledTitleDecoration + embed.Title
+		description, _ := trimStatusSuffix(embed.Description, lockedDescriptionDecoration, lockedDescriptionText)
+		embed.Description = description + cancelledDescriptionDecoration + i18n.Translate(locale, cancelledDescriptionText)
+		embed.Color = cancelledColor
+	}
+}
+
+// CloseRsvpMessage disables all components of the event message, since the RSVP deadline was reached.
+// The text that marks the RSVP as closed is added in the given locale.
+func CloseRsvpMessage(message *discord.WebhookWithComponent, locale string) {
+	forEachComponent(message.Components, func(component *discord.Component) {
+		component.Disabled = true
+	})
+	if len(message.Embeds) > 0 && !isClosed(*message) {
+		message.Embeds[0].Description += closedDescriptionDecoration + i18n.Translate(locale, closedDescriptionText)
+	}
+}
+
+// RerenderEventMessage combines a newly rendered event message with the attendees and the status of the current message.
+// The embed for the event and the components are taken from rendered, while the attendees, the waitlist, the select menu for
+// removing attendees and the locked, closed or cancelled status are taken from current.
+// The event is also shown as cancelled, if cancelled is true, e.g. since the state of the event says so.
+// The texts of the status are added in the locale of rendered.
+func (m EventMessages) RerenderEventMessage(current, rendered discord.WebhookWithComponent, locale string, cancelled bool) discord.WebhookWithComponent {
+	// copy the rendered embed and components, since they are modified below
+	result := rendered
+	result.Embeds = nil
+	if len(rendered.Embeds) > 0 {
+		eventEmbed := *rendered.Embeds[0]
+		result.Embeds = []*discordgo.MessageEmbed{&eventEmbed}
+	}
+	if len(current.Embeds) > 1 {
+		// Note: the layout of the attendees might have changed as well
+		attendees := *current.Embeds[1]
+		m.applyAttendeeLayout(&attendees, locale)
+		result.Embeds = append(result.Embeds, &attendees)
+	}
+	if len(current.Embeds) > 2 {
+		waitlist := *current.Embeds[2]
+		waitlist.Title = i18n.Translate(locale, waitlistTitle)
+		result.Embeds = append(result.Embeds, &waitlist)
+	}
+	result.Components = copyComponents(rendered.Components)
+	for _, row := range current.Components {
+		for _, component := range row.Components {
+			if customIDAction(component.CustomID) == CustomIDSelectKickAttendee && len(result.Components) < maxActionRows {
+				result.Components = append(result.Components, row)
+			}
+		}
+	}
+
+	if cancelled || isCancelled(current) {
+		m.CancelEventMessage(&result, locale)
+		return result
+	}
+	if isLocked(current.Components) {
+		m.setLocked(&result, locale, true)
+	}
+	if isClosed(current) {
+		CloseRsvpMessage(&result, locale)
+	}
+	return result
+}
+
+// isCancelled reports if the event of the message was cancelled, see CancelEventMessage.
+func isCancelled(message discord.WebhookWithComponent) bool {
+	cancelled := false
+	forEachComponent(message.Components, func(component *discord.Component) {
+		customID, err := parseCustomID(component.CustomID)
+		if err == nil && customID.Action == CustomIDButtonCancelEvent && customID.Argument() == cancelledArgument {
+			cancelled = true
+		}
+	})
+	return cancelled
+}
+
+func isClosed(message discord.WebhookWithComponent) bool {
+	if len(message.Embeds) == 0 {
+		return false
+	}
+	_, closed := trimStatusSuffix(message.Embeds[0].Description, closedDescriptionDecoration, closedDescriptionText)
+	return closed
+}
+
+// trimStatusSuffix removes the decoration and the text in any locale from the end of the description
+// and reports if it was found.
+func trimStatusSuffix(description, decoration, text string) (string, bool) {
+	for _, variant := range i18n.Variants(text) {
+		if strings.HasSuffix(description, decoration+variant) {
+			return strings.TrimSuffix(description, decoration+variant), true
+		}
+	}
+	return description, false
+}
+
+// setLocked enables or disables all components for attendees and switches the lock button.
+// The label of the button and the text that marks the RSVP as locked are set in the given locale.
+func (m EventMessages) setLocked(message *discord.WebhookWithComponent, locale string, locked bool) {
+	forEachComponent(message.Components, func(component *discord.Component) {
+		switch customIDAction(component.CustomID) {
+		case CustomIDButtonAddUserToGame, CustomIDButtonRemoveUserFromEvent, CustomIDButtonAddNote:
+			component.Disabled = locked
+		case CustomIDButtonLockEvent, CustomIDButtonUnlockEvent:
+			layout := m.organiserLayout()
+			if locked {
+				component.CustomID = EncodeCustomID(CustomIDButtonUnlockEvent)
+				component.Label = layout.UnlockLabel(locale)
+			} else {
+				component.CustomID = EncodeCustomID(CustomIDButtonLockEvent)
+				component.Label = layout.LockLabel(locale)
+			}
+		}
+	})
+	if len(message.Embeds) > 0 {
+		description, _ := trimStatusSuffix(message.Embeds[0].Description, lockedDescriptionDecoration, lockedDescriptionText)
+		if locked {
+			description += lockedDescriptionDecoration + i18n.Translate(locale, lockedDescriptionText)
+		}
+		message.Embeds[0].Description = description
+	}
+}
+
+// isLocked reports if the RSVP of the event with the given components was locked by an organiser.
+func isLocked(components []discord.Component) bool {
+	locked := false
+	forEachComponent(components, func(component *discord.Component) {
+		if customIDAction(component.CustomID) == CustomIDButtonUnlockEvent {
+			locked = true
+		}
+	})
+	return locked
+}
+
+// refreshAttendeeSelect replaces the select menu for removing attendees with one that contains all current attendees.
+// The labels of the options are taken from the previous select menu or from newNames, which maps user IDs to names.
+func refreshAttendeeSelect(message *discord.Message, locale string, newNames map[string]string) {
+	names := make(map[string]string)
+	rows := make([]discord.Component, 0, len(message.Components))
+	for _, row := range message.Components {
+		isSelectRow := false
+		for _, component := range row.Components {
+			if customIDAction(component.CustomID) == CustomIDSelectKickAttendee {
+				isSelectRow = true
+				for _, option := range component.Options {
+					names[option.Value] = option.Label
+				}
+			}
+		}
+		if !isSelectRow {
+			rows = append(rows, row)
+		}
+	}
+	for userID, name := range newNames {
+		names[userID] = name
+	}
+	message.Components = rows
+
+	options := []discord.SelectOption{}
+	seen := make(map[string]bool)
+	// Note: users on the waitlists can be removed as well
+	for index := 1; index < len(message.Embeds); index++ {
+		for _, field := range message.Embeds[index].Fields {
+			for _, user := range stringToAttendeeList(field.Value) {
+				if seen[user.UserID] || len(options) >= maxSelectOptions {
+					continue
+				}
+				seen[user.UserID] = true
+				label := names[user.UserID]
+				if label == "" {
+					label = user.UserID
+				}
+				options = append(options, discord.SelectOption{
+					Label: label,
+					Value: user.UserID,
+				})
+			}
+		}
+	}
+	// Note: the select menu is omitted, if there is no room for another action row
+	if len(options) == 0 || len(message.Components) >= maxActionRows {
+		return
+	}
+	message.Components = append(message.Components, discord.Component{
+		Type: discord.ComponentTypeActionRow,
+		Components: []discord.Component{
+			{
+				Type:        discord.ComponentTypeStringSelect,
+				CustomID:    EncodeCustomID(CustomIDSelectKickAttendee),
+				Placeholder: i18n.Translate(locale, "Remove an attendee (organisers only)"),
+				Options:     options,
+				MaxValues:   1,
+			},
+		},
+	})
+}
+
+// copyComponents returns a deep copy of the components.
+func copyComponents(components []discord.Component) []discord.Component {
+	if components == nil {
+		return nil
+	}
+	copied := make([]discord.Component, len(components))
+	for i, component := range components {
+		copied[i] = component
+		copied[i].Components = copyComponents(component.Components)
+	}
+	return copied
+}
+
+// forEachComponent calls fn for every component, including the components nested in action rows.
+func forEachComponent(components []discord.Component, fn func(component *discord.Component)) {
+	for i := range components {
+		fn(&components[i])
+		forEachComponent(components[i].Components, fn)
+	}
+}
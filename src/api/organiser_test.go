@@ -0,0 +1,266 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/discordtest"
+	"github.com/localthomas/discord-rsvp/i18n"
+)
+
+func TestRerenderEventMessage(t *testing.T) {
+	signer := discordtest.NewSigner()
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	lock := EncodeCustomID(CustomIDButtonLockEvent)
+	current := discordtest.EventMessage("Event", addGame1, lock)
+	current = updatedMessage(t, current, press(t, signer, addGame1, testUserID, current))
+	EventMessages{}.setLocked(&current.WebhookWithComponent, i18n.DefaultLocale, true)
+
+	rendered := discordtest.EventMessage("Renamed Event", addGame1, lock).WebhookWithComponent
+	result := EventMessages{}.RerenderEventMessage(current.WebhookWithComponent, rendered, i18n.DefaultLocale, false)
+
+	if len(result.Embeds) != 2 {
+		t.Fatalf("expected the embed for the attendees to be kept, got %v embeds", len(result.Embeds))
+	}
+	if result.Embeds[0].Title != "Renamed Event" {
+		t.Errorf("expected the rendered title, got %q", result.Embeds[0].Title)
+	}
+	if result.Embeds[1].Fields[0].Value != "<@"+testUserID+">" {
+		t.Errorf("unexpected attendees %q", result.Embeds[1].Fields[0].Value)
+	}
+	if _, ok := trimStatusSuffix(result.Embeds[0].Description, lockedDescriptionDecoration, lockedDescriptionText); !isLocked(result.Components) || !ok {
+		t.Errorf("expected the message to stay locked")
+	}
+	if !hasSelectMenu(result.Components) {
+		t.Errorf("expected the select menu for removing attendees to be kept")
+	}
+}
+
+func TestRerenderCancelledEventMessage(t *testing.T) {
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	cancel := EncodeCustomID(CustomIDButtonCancelEvent)
+	current := discordtest.EventMessage("Event", addGame1, cancel).WebhookWithComponent
+	EventMessages{}.CancelEventMessage(&current, i18n.DefaultLocale)
+
+	rendered := discordtest.EventMessage("Event", addGame1, cancel).WebhookWithComponent
+	result := EventMessages{}.RerenderEventMessage(current, rendered, i18n.DefaultLocale, false)
+
+	if !isCancelled(result) {
+		t.Errorf("expected the message to stay cancelled")
+	}
+	forEachComponent(result.Components, func(component *discord.Component) {
+		if component.Type != 1 && !component.Disabled {
+			t.Errorf("expected component %q to be disabled", component.CustomID)
+		}
+	})
+}
+
+func TestRerenderEventMessageCancelledInState(t *testing.T) {
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	cancel := EncodeCustomID(CustomIDButtonCancelEvent)
+	// e.g. a message that was cancelled before the status was stored in its components
+	current := discordtest.EventMessage("Event", addGame1, cancel).WebhookWithComponent
+	rendered := discordtest.EventMessage("Event", addGame1, cancel).WebhookWithComponent
+
+	result := EventMessages{}.RerenderEventMessage(current, rendered, i18n.DefaultLocale, true)
+	if !isCancelled(result) || result.Embeds[0].Title != "Cancelled: Event" {
+		t.Errorf("expected the message to be cancelled, got title %q", result.Embeds[0].Title)
+	}
+}
+
+func TestCancelledTitleDoesNotCancelEvent(t *testing.T) {
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	cancel := EncodeCustomID(CustomIDButtonCancelEvent)
+	// the title of the event can be changed via templates and must not be mistaken for the status
+	current := discordtest.EventMessage("Cancelled: Event", addGame1, cancel).WebhookWithComponent
+	rendered := discordtest.EventMessage("Cancelled: Event", addGame1, cancel).WebhookWithComponent
+
+	result := EventMessages{}.RerenderEventMessage(current, rendered, i18n.DefaultLocale, false)
+	if isCancelled(result) {
+		t.Errorf("expected the message not to be cancelled")
+	}
+	forEachComponent(result.Components, func(component *discord.Component) {
+		if component.Disabled {
+			t.Errorf("expected component %q to be enabled", component.CustomID)
+		}
+	})
+}
+
+func TestRerenderEventMessageInOtherLocale(t *testing.T) {
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	cancel := EncodeCustomID(CustomIDButtonCancelEvent)
+	current := discordtest.EventMessage("Event", addGame1, cancel).WebhookWithComponent
+	EventMessages{}.CancelEventMessage(&current, i18n.DefaultLocale)
+
+	rendered := discordtest.EventMessage("Event", addGame1, cancel).WebhookWithComponent
+	result := EventMessages{}.RerenderEventMessage(current, rendered, "de", false)
+
+	// the status is recognised in the old locale and shown in the new one
+	if !isCancelled(result) {
+		t.Fatalf("expected the message to stay cancelled, got %+v", result.Embeds[0])
+	}
+	if title := result.Embeds[0].Title; title != "Abgesagt: Event" {
+		t.Errorf("expected the title in German, got %q", title)
+	}
+	if strings.Contains(result.Embeds[0].Description, "cancelled") {
+		t.Errorf("expected the description in German, got %q", result.Embeds[0].Description)
+	}
+}
+
+func hasSelectMenu(components []discord.Component) bool {
+	found := false
+	forEachComponent(components, func(component *discord.Component) {
+		if component.Type == 3 {
+			found = true
+		}
+	})
+	return found
+}
+
+func TestRerenderEventMessageKeepsRendered(t *testing.T) {
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	cancel := EncodeCustomID(CustomIDButtonCancelEvent)
+	current := discordtest.EventMessage("Event", addGame1, cancel).WebhookWithComponent
+	current.Embeds = append(current.Embeds, &discordgo.MessageEmbed{Title: "Attendees"})
+	EventMessages{}.CancelEventMessage(&current, i18n.DefaultLocale)
+	rendered := discordtest.EventMessage("Event", addGame1, cancel).WebhookWithComponent
+
+	EventMessages{}.RerenderEventMessage(current, rendered, i18n.DefaultLocale, false)
+
+	// the rendered message is used to detect changes of the configuration and must not be modified
+	if len(rendered.Embeds) != 1 || isCancelled(rendered) {
+		t.Errorf("expected the embeds of the rendered message to be unchanged")
+	}
+	if rendered.Components[0].Components[0].Disabled {
+		t.Errorf("expected the components of the rendered message to be unchanged")
+	}
+}
+
+// selectValue sends the interaction for selecting the value in the select menu with the custom_id.
+func selectValue(t *testing.T, signer *discordtest.Signer, customID, userID, value string, message discord.Message) discord.InteractionResponse {
+	t.Helper()
+	endpoint := newTestEndpoint(t, signer)
+	recorder := signer.Send(endpoint, discordtest.SelectInteraction(customID, userID, []string{value}, message))
+	response, err := discordtest.DecodeResponse(recorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+// componentsDisabled returns whether each component of the message with the custom_id of the action is disabled.
+func componentsDisabled(message discord.Message, action string) []bool {
+	disabled := []bool{}
+	forEachComponent(message.Components, func(component *discord.Component) {
+		if customIDAction(component.CustomID) == action {
+			disabled = append(disabled, component.Disabled)
+		}
+	})
+	return disabled
+}
+
+func TestHandleLockAndUnlockEvent(t *testing.T) {
+	signer := discordtest.NewSigner()
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	remove := EncodeCustomID(CustomIDButtonRemoveUserFromEvent)
+	lock := EncodeCustomID(CustomIDButtonLockEvent)
+	unlock := EncodeCustomID(CustomIDButtonUnlockEvent)
+	message := discordtest.EventMessage("Event", addGame1, remove, lock)
+
+	message = updatedMessage(t, message, press(t, signer, lock, testUserID, message))
+	if !isLocked(message.Components) {
+		t.Fatalf("expected the lock button to be switched to unlock")
+	}
+	if disabled := componentsDisabled(message, CustomIDButtonAddUserToGame); len(disabled) != 1 || !disabled[0] {
+		t.Errorf("expected the button of Game1 to be disabled")
+	}
+	if disabled := componentsDisabled(message, CustomIDButtonUnlockEvent); len(disabled) != 1 || disabled[0] {
+		t.Errorf("expected the unlock button to be enabled")
+	}
+	if !strings.HasSuffix(message.Embeds[0].Description, lockedDescriptionDecoration+lockedDescriptionText) {
+		t.Errorf("expected the description to show the lock, got %q", message.Embeds[0].Description)
+	}
+
+	// locking again only replies to the organiser
+	response := press(t, signer, lock, testUserID, message)
+	if response.Type != 4 || response.Data.Content != lockedReply {
+		t.Errorf("expected an ephemeral reply, got %+v", response)
+	}
+
+	message = updatedMessage(t, message, press(t, signer, unlock, testUserID, message))
+	if isLocked(message.Components) {
+		t.Errorf("expected the unlock button to be switched to lock")
+	}
+	if disabled := componentsDisabled(message, CustomIDButtonAddUserToGame); len(disabled) != 1 || disabled[0] {
+		t.Errorf("expected the button of Game1 to be enabled again")
+	}
+	if message.Embeds[0].Description != "Select the games you want to play via the buttons below." {
+		t.Errorf("expected the lock to be removed from the description, got %q", message.Embeds[0].Description)
+	}
+
+	response = press(t, signer, unlock, testUserID, message)
+	if response.Type != 4 || response.Data.Content != "The RSVP for this event is not locked." {
+		t.Errorf("expected an ephemeral reply, got %+v", response)
+	}
+}
+
+func TestHandleCancelEvent(t *testing.T) {
+	signer := discordtest.NewSigner()
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	lock := EncodeCustomID(CustomIDButtonLockEvent)
+	cancel := EncodeCustomID(CustomIDButtonCancelEvent)
+	message := discordtest.EventMessage("Event", addGame1, lock, cancel)
+
+	message = updatedMessage(t, message, press(t, signer, cancel, testUserID, message))
+	if message.Embeds[0].Title != "Cancelled: Event" {
+		t.Errorf("expected the title to show the cancellation, got %q", message.Embeds[0].Title)
+	}
+	if message.Embeds[0].Color != cancelledColor {
+		t.Errorf("expected the cancelled color, got %x", message.Embeds[0].Color)
+	}
+	forEachComponent(message.Components, func(component *discord.Component) {
+		if component.Type != 1 && !component.Disabled {
+			t.Errorf("expected component %q to be disabled", component.CustomID)
+		}
+	})
+
+	// cancelling an already cancelled event does not change the message
+	previous := message
+	message = updatedMessage(t, message, press(t, signer, cancel, testUserID, message))
+	if message.Embeds[0].Title != previous.Embeds[0].Title || message.Embeds[0].Description != previous.Embeds[0].Description {
+		t.Errorf("expected the message to stay the same, got title %q and description %q", message.Embeds[0].Title, message.Embeds[0].Description)
+	}
+}
+
+func TestHandleKickAttendee(t *testing.T) {
+	signer := discordtest.NewSigner()
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	kick := EncodeCustomID(CustomIDSelectKickAttendee)
+	message := discordtest.EventMessage("Event", addGame1)
+	message = updatedMessage(t, message, press(t, signer, addGame1, testUserID, message))
+	message = updatedMessage(t, message, press(t, signer, addGame1, otherTestUserID, message))
+	if !hasSelectMenu(message.Components) {
+		t.Fatalf("expected a select menu for removing attendees")
+	}
+
+	message = updatedMessage(t, message, selectValue(t, signer, kick, testUserID, otherTestUserID, message))
+	if attendees := Attendees(message.WebhookWithComponent); len(attendees["Game1"]) != 1 || attendees["Game1"][0] != testUserID {
+		t.Errorf("expected only the first user to remain, got %v", attendees)
+	}
+	options := 0
+	forEachComponent(message.Components, func(component *discord.Component) {
+		if customIDAction(component.CustomID) == CustomIDSelectKickAttendee {
+			options = len(component.Options)
+		}
+	})
+	if options != 1 {
+		t.Errorf("expected one option in the select menu, got %v", options)
+	}
+
+	response := selectValue(t, signer, kick, testUserID, otherTestUserID, message)
+	if response.Type != 4 || response.Data.Content != "<@"+otherTestUserID+"> is not signed up for any game." {
+		t.Errorf("expected an ephemeral reply, got %+v", response)
+	}
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/i18n"
+)
+
+// The waitlist of an event message is a third embed after the embed for the attendees.
+// It has one field per full game in the same format as the embed for the attendees, e.g. "Game (2)".
+const waitlistTitle = "Waitlist"
+const waitlistColor = 0xfaa61a
+
+// gameCapacity returns the maximum number of attendees of the game or 0, if it has no limit.
+func (m EventMessages) gameCapacity(game string) int {
+	if capacity := m.GameCapacities[game]; capacity > 0 {
+		return capacity
+	}
+	return 0
+}
+
+// Waitlists returns the IDs of the users on the waitlist of each game of the event message, keyed by the game title.
+func Waitlists(message discord.WebhookWithComponent) map[string][]string {
+	waitlists := make(map[string][]string)
+	if len(message.Embeds) < 3 {
+		return waitlists
+	}
+	for _, field := range message.Embeds[2].Fields {
+		game := extractGameNameFromFieldName(field.Name)
+		for _, user := range stringToAttendeeList(field.Value) {
+			waitlists[game] = append(waitlists[game], user.UserID)
+		}
+	}
+	return waitlists
+}
+
+// Promotions returns the IDs of the users that were moved from the waitlists, as returned by Waitlists
+// for a previous state of the event message, to the attendees of the message, keyed by the game title.
+func Promotions(waitlists map[string][]string, message discord.WebhookWithComponent) map[string][]string {
+	attendees := Attendees(message)
+	promotions := make(map[string][]string)
+	for game, userIDs := range waitlists {
+		for _, userID := range userIDs {
+			if contains(attendees[game], userID) {
+				promotions[game] = append(promotions[game], userID)
+			}
+		}
+	}
+	return promotions
+}
+
+// waitlistEmbed returns the embed for the waitlist of the message, which is added if create is true.
+// If the message has no waitlist and create is false, nil is returned.
+func waitlistEmbed(message *discord.Message, locale string, create bool) *discordgo.MessageEmbed {
+	if len(message.Embeds) > 2 {
+		return message.Embeds[2]
+	}
+	if !create || len(message.Embeds) < 2 {
+		return nil
+	}
+	embed := &discordgo.MessageEmbed{
+		Title: i18n.Translate(locale, waitlistTitle),
+		Color: waitlistColor,
+	}
+	message.Embeds = append(message.Embeds, embed)
+	return embed
+}
+
+// findGameField returns the field for the game in the embed or nil, if there is none.
+func findGameField(embed *discordgo.MessageEmbed, game string) *discordgo.MessageEmbedField {
+	for _, field := range embed.Fields {
+		if extractGameNameFromFieldName(field.Name) == game {
+			return field
+		}
+	}
+	return nil
+}
+
+// setGameField sets the users of the field for the game, which is added or removed as needed.
+func setGameField(embed *discordgo.MessageEmbed, game string, users []attendee) {
+	field := findGameField(embed, game)
+	if len(users) == 0 {
+		for index, existing := range embed.Fields {
+			if existing == field {
+				embed.Fields = append(embed.Fields[:index], embed.Fields[index+1:]...)
+				break
+			}
+		}
+		return
+	}
+	if field == nil {
+		field = &discordgo.MessageEmbedField{
+			Inline: true,
+		}
+		embed.Fields = append(embed.Fields, field)
+	}
+	field.Value = attendeeListToString(users)
+	// set the field title to "Game (2)", where 2 is the number of users
+	field.Name = game + fmt.Sprintf(" (%v)", len(users))
+}
+
+// addToWaitlist puts the user at the end of the waitlist of the game and returns the position of the user.
+// If the user is already on the waitlist, the current position is returned.
+// If the user does not fit into the field of the waitlist, the position is 0.
+func addToWaitlist(message *discord.Message, locale, game string, user attendee) (int, bool) {
+	embed := waitlistEmbed(message, locale, true)
+	users := []attendee{}
+	if field := findGameField(embed, game); field != nil {
+		users = stringToAttendeeList(field.Value)
+	}
+	for index, waiting := range users {
+		if waiting.UserID == user.UserID {
+			return index + 1, false
+		}
+	}
+	user, fits := fitAttendee(users, user)
+	if !fits {
+		return 0, false
+	}
+	users = append(users, user)
+	setGameField(embed, game, users)
+	return len(users), true
+}
+
+// promoteWaitlistedUsers moves the first users of the waitlists to the attendees of their games,
+// until the games are full again. The embed for the waitlist is removed, once it is empty.
+func (m EventMessages) promoteWaitlistedUsers(message *discord.Message, locale string) {
+	waitlist := waitlistEmbed(message, locale, false)
+	if waitlist == nil {
+		return
+	}
+	attendees := message.Embeds[1]
+	// Note: the fields are copied, since promoting a user can remove a field of the waitlist
+	for _, field := range append([]*discordgo.MessageEmbedField{}, waitlist.Fields...) {
+		game := extractGameNameFromFieldName(field.Name)
+		waiting := stringToAttendeeList(field.Value)
+		players := []attendee{}
+		if playerField := findGameField(attendees, game); playerField != nil {
+			players = stringToAttendeeList(playerField.Value)
+		}
+		capacity := m.gameCapacity(game)
+		// Note: the field of the game must not exceed the limit of Discord, even if the game is not full
+		for len(waiting) > 0 && (capacity == 0 || len(players) < capacity) && fitsField(attendeeListToString(append(players, waiting[0]))) {
+			players = append(players, waiting[0])
+			waiting = waiting[1:]
+		}
+		setGameField(attendees, game, players)
+		setGameField(waitlist, game, waiting)
+	}
+	if len(waitlist.Fields) == 0 {
+		message.Embeds = message.Embeds[:2]
+	}
+}
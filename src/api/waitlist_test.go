@@ -0,0 +1,50 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/localthomas/discord-rsvp/discordtest"
+)
+
+func TestWaitlist(t *testing.T) {
+	signer := discordtest.NewSigner()
+	_, endpoint := newTestRouter(t, signer, EventMessages{
+		GameCapacities: map[string]int{"Game1": 1},
+	})
+	addGame1 := EncodeCustomID(CustomIDButtonAddUserToGame, "Game1")
+	remove := EncodeCustomID(CustomIDButtonRemoveUserFromEvent)
+	message := discordtest.EventMessage("Event", addGame1, remove)
+
+	message = updatedMessage(t, message, pressAt(t, endpoint, signer, addGame1, testUserID, message))
+	message = updatedMessage(t, message, pressAt(t, endpoint, signer, addGame1, otherTestUserID, message))
+	if len(message.Embeds) != 3 {
+		t.Fatalf("expected an embed for the waitlist, got %v embeds", len(message.Embeds))
+	}
+	if attendees := Attendees(message.WebhookWithComponent); !reflect.DeepEqual(attendees, map[string][]string{"Game1": {testUserID}}) {
+		t.Errorf("expected only the first user as attendee, got %v", attendees)
+	}
+	waitlists := Waitlists(message.WebhookWithComponent)
+	if !reflect.DeepEqual(waitlists, map[string][]string{"Game1": {otherTestUserID}}) {
+		t.Errorf("expected the second user on the waitlist, got %v", waitlists)
+	}
+
+	// signing up again keeps the position on the waitlist
+	response := pressAt(t, endpoint, signer, addGame1, otherTestUserID, message)
+	if response.Type != 4 || response.Data.Content != "You are already on the waitlist for Game1 at position 1." {
+		t.Errorf("expected an ephemeral reply, got %+v", response)
+	}
+
+	// leaving the game promotes the first user of the waitlist
+	message = updatedMessage(t, message, pressAt(t, endpoint, signer, remove, testUserID, message))
+	if len(message.Embeds) != 2 {
+		t.Fatalf("expected the waitlist to be removed, got %v embeds", len(message.Embeds))
+	}
+	promotions := Promotions(waitlists, message.WebhookWithComponent)
+	if !reflect.DeepEqual(promotions, map[string][]string{"Game1": {otherTestUserID}}) {
+		t.Errorf("expected the second user to be promoted, got %v", promotions)
+	}
+	if name := message.Embeds[1].Fields[0].Name; name != "Game1 (1)" {
+		t.Errorf("unexpected field name %q", name)
+	}
+}
@@ -3,8 +3,15 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"sort"
+	"strings"
 	"time"
+
+	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/i18n"
 )
 
 // Config can be used to read the configuration of the software
@@ -13,13 +20,297 @@ type Config struct {
 	ThisInstanceURL            string
 	ClientID                   string
 	ClientSecret               string
-	Games                      map[string]string
-	Events                     map[string]Event
+	// Games contains the games that can be chosen for every event, keyed by their title.
+	// A game is either a description or an object, see Game.
+	Games  map[string]Game
+	Events map[string]Event
+	// SignatureTimestampSkew is the maximum difference between the timestamp of a request from Discord
+	// and the local time, e.g. "5m". Older requests are rejected to prevent replay attacks.
+	SignatureTimestampSkew string
+	// OrganiserRoles contains the IDs of the roles that can lock and cancel events and remove attendees,
+	// in addition to members with the Administrator or Manage Events permission
+	OrganiserRoles []string
+	// DiscordAPIBaseURL replaces the base URL of the API of Discord, e.g. for tests against a local server.
+	// If empty, discord.DefaultAPIBaseURL is used.
+	DiscordAPIBaseURL string
+	// BotToken is the token of the bot user of the application.
+	// If set together with GuildID, each event is mirrored as Guild Scheduled Event.
+	// If set together with ChannelID, the event messages are posted by the bot instead of the webhook.
+	BotToken string
+	// ChannelID is the channel the bot posts the event messages to, unless an event has its own ChannelID.
+	// If empty, the messages are sent via the webhook of the OAuth2 flow.
+	ChannelID string
+	// GuildID is the ID of the guild the Guild Scheduled Events are created in
+	GuildID string
+	// Locale is the language of the event messages, e.g. "de", unless an event has its own Locale.
+	// See i18n.Locales for all supported locales. Defaults to i18n.DefaultLocale.
+	Locale string
+	// TimestampStyle is the style in which the start of an event is shown in the messages, e.g. "f".
+	// See discord.TimestampStyle for all styles. Defaults to "F".
+	TimestampStyle string
+	// Layout customises the event messages via templates
+	Layout Layout
+	// templates are parsed from Layout by ReadConfig, see Config.eventTemplates
+	templates *eventTemplates
+	// EventThreads creates a thread on each message posted by the bot, in which changes of the attendees are posted.
+	// The thread is archived when the event is removed. Requires a BotToken.
+	EventThreads bool
+	// FinalLineup closes the RSVP at the start of each event and posts the attendees of each game in a separate message,
+	// which is kept after the event message is deleted
+	FinalLineup bool
+	// MetricsListenAddress is the address of a separate HTTP server for the metrics, e.g. "127.0.0.1:9090".
+	// If empty, the metrics are not served, since they should not be public.
+	MetricsListenAddress string
+}
+
+// EventChannelID returns the channel the bot posts the messages of the event to,
+// or an empty string, if the messages are sent via the webhook.
+func (c Config) EventChannelID(title string) string {
+	if channelID := c.Events[title].ChannelID; channelID != "" {
+		return channelID
+	}
+	return c.ChannelID
+}
+
+// EventLocale returns the language of the messages of the event.
+func (c Config) EventLocale(title string) string {
+	if locale := c.Events[title].Locale; locale != "" {
+		return locale
+	}
+	if c.Locale != "" {
+		return c.Locale
+	}
+	return i18n.DefaultLocale
+}
+
+// eventTemplates returns the parsed templates of the Layout or the default templates,
+// if the configuration was not read via ReadConfig.
+func (c Config) eventTemplates() *eventTemplates {
+	if c.templates == nil {
+		return defaultTemplates
+	}
+	return c.templates
+}
+
+// UsesWebhook reports if the messages of at least one event are sent via the webhook of the OAuth2 flow.
+func (c Config) UsesWebhook() bool {
+	for title := range c.Events {
+		if c.EventChannelID(title) == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ScheduledEventsEnabled reports if events are mirrored as Guild Scheduled Events.
+func (c Config) ScheduledEventsEnabled() bool {
+	return c.BotToken != "" && c.GuildID != ""
+}
+
+// StartTimeStyle returns the configured TimestampStyle or the default value, if it is not set.
+func (c Config) StartTimeStyle() discord.TimestampStyle {
+	if c.TimestampStyle == "" {
+		return discord.TimestampStyleLongDateTime
+	}
+	return discord.TimestampStyle(c.TimestampStyle)
+}
+
+// APIBaseURL returns the configured DiscordAPIBaseURL or the default value, if it is not set.
+func (c Config) APIBaseURL() string {
+	if c.DiscordAPIBaseURL == "" {
+		return discord.DefaultAPIBaseURL
+	}
+	return c.DiscordAPIBaseURL
+}
+
+// MaxTimestampSkew returns the configured SignatureTimestampSkew or the default value, if it is not set.
+func (c Config) MaxTimestampSkew() time.Duration {
+	if c.SignatureTimestampSkew == "" {
+		return discord.DefaultMaxTimestampSkew
+	}
+	// Note: the value was validated when reading the config
+	skew, _ := time.ParseDuration(c.SignatureTimestampSkew)
+	return skew
+}
+
+// Game describes a game of the event messages. All fields except the Description are optional.
+type Game struct {
+	// Description is shown below the title of the game and can contain markdown
+	Description string
+	// Emoji is shown on the button and in front of the title, either as unicode emoji like "🎲"
+	// or as custom emoji like "<:dice:123456>"
+	Emoji string
+	// ButtonStyle overrides the GameButtonStyle of the Layout for the button of this game
+	ButtonStyle string
+	// Image is the URL of an image, which is linked in the field of the game.
+	// The image of the first game is also shown as thumbnail of the event message.
+	Image string
+	// URL links further information about the game, e.g. the website or the rules
+	URL string
+	// MinPlayers and MaxPlayers are the number of players the game needs and allows, 0 means no limit
+	MinPlayers int
+	MaxPlayers int
+	// Order moves the game in the event message, games with a lower order come first.
+	// Games with the same order are sorted by title.
+	Order int
+}
+
+// UnmarshalJSON decodes the game from an object or from a single string, which is used as Description.
+// Note: the string form was the only form before games were objects and is kept for existing configurations
+func (g *Game) UnmarshalJSON(data []byte) error {
+	description := ""
+	if err := json.Unmarshal(data, &description); err == nil {
+		*g = Game{Description: description}
+		return nil
+	}
+	// Note: the alias type does not have the UnmarshalJSON method, which would recurse
+	type game Game
+	return json.Unmarshal(data, (*game)(g))
+}
+
+// ButtonEmoji returns the parsed Emoji or nil, if it is not set.
+func (g Game) ButtonEmoji() *discord.Emoji {
+	if g.Emoji == "" {
+		return nil
+	}
+	// Note: the value was validated when reading the config
+	emoji, _ := discord.ParseEmoji(g.Emoji)
+	return emoji
+}
+
+// validate checks the optional fields of the game.
+func (g Game) validate() error {
+	if g.Emoji != "" {
+		if _, err := discord.ParseEmoji(g.Emoji); err != nil {
+			return fmt.Errorf("invalid Emoji: %w", err)
+		}
+	}
+	if _, ok := buttonStyles[g.ButtonStyle]; g.ButtonStyle != "" && !ok {
+		return fmt.Errorf("invalid ButtonStyle %v: must be one of primary, secondary, success and danger", g.ButtonStyle)
+	}
+	for name, value := range map[string]string{"Image": g.Image, "URL": g.URL} {
+		if value == "" {
+			continue
+		}
+		if parsed, err := url.ParseRequestURI(value); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return fmt.Errorf("invalid %v %v: must be a http or https URL", name, value)
+		}
+	}
+	if g.MinPlayers < 0 || g.MaxPlayers < 0 {
+		return fmt.Errorf("MinPlayers and MaxPlayers must not be negative")
+	}
+	if g.MaxPlayers > 0 && g.MinPlayers > g.MaxPlayers {
+		return fmt.Errorf("MinPlayers must not be greater than MaxPlayers")
+	}
+	return nil
 }
 
 type Event struct {
 	FirstTime time.Time
 	Repeat    string
+	// AllowedRoles contains the IDs of the roles that can sign up for the event.
+	// If empty, everyone can sign up.
+	AllowedRoles []string
+	// RsvpCloses is the duration before the start of the event, after which no changes to the attendees are possible,
+	// e.g. "2h". If empty, the RSVP stays open.
+	RsvpCloses string
+	// Duration is the length of the event shown in the Guild Scheduled Event, e.g. "3h". Defaults to defaultEventDuration.
+	Duration string
+	// Location is shown in the Guild Scheduled Event. Defaults to defaultEventLocation.
+	Location string
+	// ChannelID overrides the ChannelID of the configuration for this event
+	ChannelID string
+	// Locale overrides the Locale of the configuration for this event
+	Locale string
+	// Reminders contains durations before the start of the event, e.g. ["24h", "15m"],
+	// at which everyone who signed up is mentioned in a reminder message.
+	Reminders []string
+	// ConfirmGames is the duration before the start of the event, e.g. "3h", at which each game is marked as happening
+	// or not, depending on the MinPlayers of the game and the MinAttendees of the event. If empty, games are not confirmed.
+	ConfirmGames string
+	// MinAttendees is the number of distinct attendees the event needs, otherwise no game is happening
+	MinAttendees int
+	// CancelIfNoGame cancels the event, if no game is happening at the time of ConfirmGames
+	CancelIfNoGame bool
+}
+
+const defaultEventDuration = 2 * time.Hour
+const defaultEventLocation = "Discord"
+
+// EndsAt returns the end of an instance of the event that starts at startsAt.
+func (e Event) EndsAt(startsAt time.Time) time.Time {
+	if e.Duration == "" {
+		return startsAt.Add(defaultEventDuration)
+	}
+	// Note: the value was validated when reading the config
+	duration, _ := time.ParseDuration(e.Duration)
+	return startsAt.Add(duration)
+}
+
+// EventLocation returns the configured Location or the default value, if it is not set.
+func (e Event) EventLocation() string {
+	if e.Location == "" {
+		return defaultEventLocation
+	}
+	return e.Location
+}
+
+// OccursAt reports if an instance of the event starts at t according to FirstTime and Repeat.
+func (e Event) OccursAt(t time.Time) bool {
+	possibleTime := e.FirstTime
+	for !possibleTime.After(t) {
+		if possibleTime.Equal(t) {
+			return true
+		}
+		switch e.Repeat {
+		case "daily":
+			possibleTime = possibleTime.AddDate(0, 0, 1)
+		case "weekly":
+			possibleTime = possibleTime.AddDate(0, 0, 7)
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// RsvpClosesAt returns the RSVP deadline for an instance of the event that starts at startsAt.
+// If the event has no deadline, false is returned.
+func (e Event) RsvpClosesAt(startsAt time.Time) (time.Time, bool) {
+	if e.RsvpCloses == "" {
+		return time.Time{}, false
+	}
+	// Note: the value was validated when reading the config
+	offset, _ := time.ParseDuration(e.RsvpCloses)
+	return startsAt.Add(-offset), true
+}
+
+// ConfirmGamesAt returns the time at which the games of an instance of the event that starts at startsAt are confirmed.
+// If the games of the event are not confirmed, false is returned.
+func (e Event) ConfirmGamesAt(startsAt time.Time) (time.Time, bool) {
+	if e.ConfirmGames == "" {
+		return time.Time{}, false
+	}
+	// Note: the value was validated when reading the config
+	offset, _ := time.ParseDuration(e.ConfirmGames)
+	return startsAt.Add(-offset), true
+}
+
+// DueReminders returns the Reminders whose time was reached at now for an instance of the event that starts
+// at startsAt, ordered from the earliest to the latest reminder.
+func (e Event) DueReminders(startsAt, now time.Time) []time.Duration {
+	due := []time.Duration{}
+	for _, reminder := range e.Reminders {
+		// Note: the value was validated when reading the config
+		offset, _ := time.ParseDuration(reminder)
+		if !now.Before(startsAt.Add(-offset)) {
+			due = append(due, offset)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i] > due[j]
+	})
+	return due
 }
 
 func ReadConfig(path string) (Config, error) {
@@ -32,5 +323,85 @@ func ReadConfig(path string) (Config, error) {
 	if err != nil {
 		return Config{}, fmt.Errorf("could not parse config file: %w", err)
 	}
+	if config.SignatureTimestampSkew != "" {
+		if skew, err := time.ParseDuration(config.SignatureTimestampSkew); err != nil || skew <= 0 {
+			return Config{}, fmt.Errorf("invalid SignatureTimestampSkew: must be a positive duration")
+		}
+	}
+	if config.DiscordAPIBaseURL != "" {
+		if _, err := url.ParseRequestURI(config.DiscordAPIBaseURL); err != nil {
+			return Config{}, fmt.Errorf("invalid DiscordAPIBaseURL: %w", err)
+		}
+	}
+	if config.MetricsListenAddress != "" {
+		if _, _, err := net.SplitHostPort(config.MetricsListenAddress); err != nil {
+			return Config{}, fmt.Errorf("invalid MetricsListenAddress: %w", err)
+		}
+	}
+	if config.Locale != "" && !i18n.Supported(config.Locale) {
+		return Config{}, fmt.Errorf("unsupported Locale %v: must be one of %v", config.Locale, strings.Join(i18n.Locales(), ", "))
+	}
+	config.templates, err = parseTemplates(config.Layout)
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid Layout: %w", err)
+	}
+	if config.TimestampStyle != "" && !discord.TimestampStyle(config.TimestampStyle).Valid() {
+		return Config{}, fmt.Errorf("invalid TimestampStyle %v: must be one of t, T, d, D, f, F and R", config.TimestampStyle)
+	}
+	if config.BotToken == "" {
+		if config.EventThreads {
+			return Config{}, fmt.Errorf("EventThreads requires a BotToken")
+		}
+		if config.ChannelID != "" {
+			return Config{}, fmt.Errorf("ChannelID requires a BotToken")
+		}
+		for title, event := range config.Events {
+			if event.ChannelID != "" {
+				return Config{}, fmt.Errorf("ChannelID of event %v requires a BotToken", title)
+			}
+		}
+	}
+	// Note: a message has at most 5 action rows of 5 buttons and one row is used by the other buttons
+	if len(config.Games) > 20 {
+		return Config{}, fmt.Errorf("too many Games: at most 20 games are supported, got %v", len(config.Games))
+	}
+	for title, game := range config.Games {
+		if err := game.validate(); err != nil {
+			return Config{}, fmt.Errorf("invalid game %v: %w", title, err)
+		}
+	}
+	for title, event := range config.Events {
+		if event.RsvpCloses != "" {
+			if offset, err := time.ParseDuration(event.RsvpCloses); err != nil || offset <= 0 {
+				return Config{}, fmt.Errorf("invalid RsvpCloses of event %v: must be a positive duration", title)
+			}
+		}
+		if event.Duration != "" {
+			if duration, err := time.ParseDuration(event.Duration); err != nil || duration <= 0 {
+				return Config{}, fmt.Errorf("invalid Duration of event %v: must be a positive duration", title)
+			}
+		}
+		if event.Locale != "" && !i18n.Supported(event.Locale) {
+			return Config{}, fmt.Errorf("unsupported Locale %v of event %v: must be one of %v", event.Locale, title, strings.Join(i18n.Locales(), ", "))
+		}
+		for _, reminder := range event.Reminders {
+			if offset, err := time.ParseDuration(reminder); err != nil || offset <= 0 {
+				return Config{}, fmt.Errorf("invalid reminder %v of event %v: must be a positive duration", reminder, title)
+			}
+		}
+		if event.ConfirmGames != "" {
+			if offset, err := time.ParseDuration(event.ConfirmGames); err != nil || offset <= 0 {
+				return Config{}, fmt.Errorf("invalid ConfirmGames of event %v: must be a positive duration", title)
+			}
+		} else if event.CancelIfNoGame || event.MinAttendees != 0 {
+			return Config{}, fmt.Errorf("MinAttendees and CancelIfNoGame of event %v require ConfirmGames", title)
+		}
+		if event.MinAttendees < 0 {
+			return Config{}, fmt.Errorf("invalid MinAttendees of event %v: must not be negative", title)
+		}
+		if len(event.Location) > 100 {
+			return Config{}, fmt.Errorf("invalid Location of event %v: must not be longer than 100 characters", title)
+		}
+	}
 	return config, nil
 }
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/localthomas/discord-rsvp/api"
+	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/i18n"
+)
+
+// maxMessageContentLength is the maximum length of the content of a message allowed by Discord.
+const maxMessageContentLength = 2000
+
+// confirmGames decides which games of the event are happening, based on the attendees of the event message,
+// and posts a summary next to the event message. The event is cancelled, if no game is happening and the event
+// is configured to be cancelled in that case. The event message shows the result after the next re-rendering.
+func confirmGames(clients discordClients, state *State, config Config, event RsvpEvent) error {
+	current, ok := state.RsvpEvent(event.Title, event.StartsAt)
+	if !ok || (current.Cancelled && current.GamesHappening == nil) {
+		return nil
+	}
+	var attendees map[string][]string
+	var happening map[string]bool
+	cancel := false
+	err := changeEventMessage(clients, current, func(message *discord.WebhookWithComponent) bool {
+		attendees = api.Attendees(*message)
+		// Note: the decision is only made once, a retry only posts the summary again
+		if current.GamesHappening != nil {
+			return false
+		}
+		happening = gamesHappening(config, current, attendees)
+		cancel = !anyHappening(happening) && config.Events[current.Title].CancelIfNoGame
+		if cancel {
+			eventMessages(config).CancelEventMessage(message, config.EventLocale(current.Title))
+		}
+		return cancel
+	})
+	if err != nil {
+		return err
+	}
+
+	if current.GamesHappening == nil {
+		if cancel {
+			state.SetCancelled(current.Title, current.StartsAt)
+			if current.ScheduledEventID != "" {
+				state.AddOperation(Operation{
+					Kind:  OperationCancelScheduledEvent,
+					Event: current,
+				})
+			}
+		}
+		state.SetGamesHappening(current.Title, current.StartsAt, happening)
+		current, _ = state.RsvpEvent(current.Title, current.StartsAt)
+	}
+
+	_, err = postEventMessage(clients, current, discord.WebhookWithComponent{
+		WebhookParams: discordgo.WebhookParams{
+			Content: confirmationSummary(config, current, attendees),
+			// Note: an empty list of mention types suppresses all notifications
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		},
+	})
+	return err
+}
+
+// gamesHappening returns for each game, whether it has enough attendees. No game is happening,
+// if the event does not have its MinAttendees.
+func gamesHappening(config Config, event RsvpEvent, attendees map[string][]string) map[string]bool {
+	enoughAttendees := len(attendeeIDs(attendees)) >= config.Events[event.Title].MinAttendees
+	happening := make(map[string]bool)
+	for title, game := range config.Games {
+		players := len(attendees[title])
+		happening[title] = enoughAttendees && players > 0 && players >= game.MinPlayers
+	}
+	return happening
+}
+
+func anyHappening(happening map[string]bool) bool {
+	for _, isHappening := range happening {
+		if isHappening {
+			return true
+		}
+	}
+	return false
+}
+
+// confirmationSummary lists the games of the event with their status, in the order of the event message.
+func confirmationSummary(config Config, event RsvpEvent, attendees map[string][]string) string {
+	locale := config.EventLocale(event.Title)
+	eventConfig := config.Events[event.Title]
+	lines := []string{i18n.Sprintf(locale, "Games of **%v**, which starts %v:", event.Title, formatStartTime(event, config))}
+	for _, game := range gamesToList(config.Games) {
+		players := len(attendees[game.Title])
+		if event.GamesHappening[game.Title] {
+			lines = append(lines, "✅ "+i18n.Sprintf(locale, "**%v** is happening with %v players.", game.Title, players))
+		} else if game.MinPlayers > 0 {
+			lines = append(lines, "❌ "+i18n.Sprintf(locale, "**%v** is not happening with %v of at least %v players.", game.Title, players, game.MinPlayers))
+		} else {
+			lines = append(lines, "❌ "+i18n.Sprintf(locale, "**%v** is not happening with %v players.", game.Title, players))
+		}
+	}
+	if count := len(attendeeIDs(attendees)); count < eventConfig.MinAttendees {
+		lines = append(lines, i18n.Sprintf(locale, "The event needs at least %v attendees, but only %v signed up.", eventConfig.MinAttendees, count))
+	}
+	if event.Cancelled {
+		lines = append(lines, i18n.Translate(locale, "The event was cancelled, since no game is happening."))
+	}
+	return truncate(strings.Join(lines, "\n"), maxMessageContentLength)
+}
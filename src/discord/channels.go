@@ -0,0 +1,108 @@
+package discord
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// CreateChannelMessage posts the message to the channel. The client requires a bot token with the permission
+// to send messages in the channel.
+// https://discord.com/developers/docs/resources/message#create-message
+func CreateChannelMessage(client *Client, channelID string, data WebhookWithComponent) (*Message, error) {
+	message := &Message{}
+	err := client.Request(http.MethodPost, channelMessagesPath(channelID), data, message)
+	if err != nil {
+		return nil, fmt.Errorf("could not create channel message: %w", err)
+	}
+	return message, nil
+}
+
+// GetChannelMessage returns the content, embeds and components of a message in the channel.
+// https://discord.com/developers/docs/resources/message#get-channel-message
+func GetChannelMessage(client *Client, channelID, messageID string) (WebhookWithComponent, error) {
+	message := Message{}
+	err := client.Request(http.MethodGet, channelMessagesPath(channelID)+"/"+messageID, nil, &message)
+	if err != nil {
+		return WebhookWithComponent{}, fmt.Errorf("could not get channel message: %w", err)
+	}
+	return message.WebhookWithComponent, nil
+}
+
+// EditChannelMessage replaces the content, embeds and components of a message previously posted by the bot.
+// The edited message is returned.
+// https://discord.com/developers/docs/resources/message#edit-message
+func EditChannelMessage(client *Client, channelID, messageID string, data WebhookWithComponent) (*Message, error) {
+	message := &Message{}
+	err := client.Request(http.MethodPatch, channelMessagesPath(channelID)+"/"+messageID, data, message)
+	if err != nil {
+		return nil, fmt.Errorf("could not edit channel message: %w", err)
+	}
+	return message, nil
+}
+
+// DeleteChannelMessage deletes a message previously posted by the bot. A thread started on the message is kept.
+// https://discord.com/developers/docs/resources/message#delete-message
+func DeleteChannelMessage(client *Client, channelID, messageID string) error {
+	err := client.Request(http.MethodDelete, channelMessagesPath(channelID)+"/"+messageID, nil, nil)
+	if err != nil {
+		return fmt.Errorf("could not delete channel message: %w", err)
+	}
+	return nil
+}
+
+// Channel is a channel or thread of a guild. Only the fields used by this software are included.
+// https://discord.com/developers/docs/resources/channel#channel-object
+type Channel struct {
+	ID       string `json:"id"`
+	Type     int    `json:"type"`
+	GuildID  string `json:"guild_id,omitempty"`
+	ParentID string `json:"parent_id,omitempty"`
+	Name     string `json:"name,omitempty"`
+}
+
+// ThreadAutoArchiveDuration is the inactivity in minutes after which Discord archives a thread, one of 60, 1440, 4320
+// and 10080.
+type ThreadAutoArchiveDuration int
+
+// ThreadAutoArchiveOneWeek is the longest duration allowed by Discord.
+const ThreadAutoArchiveOneWeek ThreadAutoArchiveDuration = 10080
+
+// StartThreadFromMessage creates a public thread attached to a message in the channel.
+// The name of the thread must not be longer than 100 characters.
+// https://discord.com/developers/docs/resources/channel#start-thread-from-message
+func StartThreadFromMessage(client *Client, channelID, messageID, name string, autoArchiveDuration ThreadAutoArchiveDuration) (*Channel, error) {
+	data := struct {
+		Name                string                    `json:"name"`
+		AutoArchiveDuration ThreadAutoArchiveDuration `json:"auto_archive_duration,omitempty"`
+	}{
+		Name:                name,
+		AutoArchiveDuration: autoArchiveDuration,
+	}
+	thread := &Channel{}
+	err := client.Request(http.MethodPost, channelMessagesPath(channelID)+"/"+messageID+"/threads", data, thread)
+	if err != nil {
+		return nil, fmt.Errorf("could not start thread: %w", err)
+	}
+	return thread, nil
+}
+
+// ArchiveThread archives and locks the thread, so that only moderators can post to it or unarchive it.
+// https://discord.com/developers/docs/resources/channel#modify-channel-json-params-thread
+func ArchiveThread(client *Client, threadID string) error {
+	data := struct {
+		Archived bool `json:"archived"`
+		Locked   bool `json:"locked"`
+	}{
+		Archived: true,
+		Locked:   true,
+	}
+	err := client.Request(http.MethodPatch, "/channels/"+threadID, data, nil)
+	if err != nil {
+		return fmt.Errorf("could not archive thread: %w", err)
+	}
+	return nil
+}
+
+func channelMessagesPath(channelID string) string {
+	return "/channels/" + channelID + "/messages"
+}
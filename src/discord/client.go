@@ -0,0 +1,259 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAPIBaseURL is the base URL of the REST API of Discord, including the version.
+const DefaultAPIBaseURL = "https://discord.com/api/v10"
+
+// Client sends requests to the REST API of Discord and keeps track of its rate limits.
+// Instead of waiting for a rate limit to reset, requests fail with a RateLimitError,
+// so that the caller can decide when to try again.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	userAgent  string
+	// authorization is the value of the Authorization header and empty for clients without a bot token
+	authorization string
+
+	// mutex guards the fields below
+	mutex sync.Mutex
+	// blockedRoutes maps a route to the time its rate limit resets, if no requests are remaining
+	blockedRoutes map[string]time.Time
+	// blockedGlobally is the time the global rate limit resets
+	blockedGlobally time.Time
+}
+
+// RateLimitError is returned for requests that were rejected or not sent because of a rate limit.
+type RateLimitError struct {
+	RetryAfter time.Duration
+	Global     bool
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Global {
+		return fmt.Sprintf("global rate limit reached, retry after %v", e.RetryAfter)
+	}
+	return fmt.Sprintf("rate limit reached, retry after %v", e.RetryAfter)
+}
+
+// APIError is returned for responses with a not-ok status code other than 429.
+type APIError struct {
+	StatusCode int
+	// Code is the JSON error code of Discord, e.g. 10008 for an unknown message
+	Code    int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("request had not-ok status code %v: %v (code %v)", e.StatusCode, e.Message, e.Code)
+}
+
+// IsRetryable reports if a request that failed with the error might succeed when sent again.
+// Client errors, like a deleted message or an invalid body, will fail again.
+func IsRetryable(err error) bool {
+	var apiError *APIError
+	if errors.As(err, &apiError) {
+		return apiError.StatusCode >= 500
+	}
+	return true
+}
+
+// NewClient creates a client that sends requests to the API at baseURL (e.g. DefaultAPIBaseURL)
+// and identifies itself with the user agent.
+// See https://discord.com/developers/docs/reference#user-agent for the required format.
+func NewClient(baseURL, userAgent string) *Client {
+	return &Client{
+		httpClient:    &http.Client{Timeout: 20 * time.Second},
+		baseURL:       strings.TrimSuffix(baseURL, "/"),
+		userAgent:     userAgent,
+		blockedRoutes: make(map[string]time.Time),
+	}
+}
+
+// NewBotClient creates a client like NewClient, that authorizes all requests with the token of a bot user.
+// https://discord.com/developers/docs/reference#authentication
+func NewBotClient(baseURL, userAgent, botToken string) *Client {
+	client := NewClient(baseURL, userAgent)
+	client.authorization = "Bot " + botToken
+	return client
+}
+
+// Request sends a request with the data encoded as JSON body to the path relative to the API base URL.
+// If data is of type url.Values, it is sent as form instead.
+// If result is not nil, the response body is decoded into it.
+func (c *Client) Request(method, path string, data, result interface{}) error {
+	requestURL := c.baseURL + path
+	route := routeOf(method, requestURL)
+	if err := c.checkRateLimit(route); err != nil {
+		return err
+	}
+
+	var body io.Reader
+	contentType := ""
+	switch data := data.(type) {
+	case nil:
+	case url.Values:
+		// Note: the OAuth2 endpoints only accept form data
+		body = strings.NewReader(data.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	default:
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("could not marshal request body: %w", err)
+		}
+		body = bytes.NewReader(encoded)
+		contentType = "application/json"
+	}
+	r, err := http.NewRequest(method, requestURL, body)
+	if err != nil {
+		return fmt.Errorf("could not create request: %w", err)
+	}
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	r.Header.Set("User-Agent", c.userAgent)
+	if c.authorization != "" {
+		r.Header.Set("Authorization", c.authorization)
+	}
+
+	response, err := c.httpClient.Do(r)
+	if err != nil {
+		return fmt.Errorf("could not make %v request: %w", method, err)
+	}
+	defer response.Body.Close()
+	c.updateRateLimit(route, response.Header)
+
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("could not read response body: %w", err)
+	}
+
+	if response.StatusCode == http.StatusTooManyRequests {
+		return c.handleTooManyRequests(route, response.Header, responseBody)
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		apiError := &APIError{
+			StatusCode: response.StatusCode,
+			Message:    string(responseBody),
+		}
+		// Note: the body is not always JSON, e.g. for errors of proxies
+		errorBody := struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+			// OAuth2 errors use the format of RFC 6749 instead
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}{}
+		if json.Unmarshal(responseBody, &errorBody) == nil {
+			if errorBody.Message != "" {
+				apiError.Code = errorBody.Code
+				apiError.Message = errorBody.Message
+			} else if errorBody.Error != "" {
+				apiError.Message = errorBody.Error + ": " + errorBody.ErrorDescription
+			}
+		}
+		return apiError
+	}
+
+	if result != nil {
+		err = json.Unmarshal(responseBody, result)
+		if err != nil {
+			return fmt.Errorf("received unknown JSON data: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkRateLimit returns a RateLimitError, if the route or all routes are currently blocked.
+func (c *Client) checkRateLimit(route string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	if now.Before(c.blockedGlobally) {
+		return &RateLimitError{
+			RetryAfter: c.blockedGlobally.Sub(now),
+			Global:     true,
+		}
+	}
+	if blockedUntil, ok := c.blockedRoutes[route]; ok {
+		if now.Before(blockedUntil) {
+			return &RateLimitError{
+				RetryAfter: blockedUntil.Sub(now),
+			}
+		}
+		delete(c.blockedRoutes, route)
+	}
+	return nil
+}
+
+// updateRateLimit blocks the route until its reset, if no requests are remaining.
+// https://discord.com/developers/docs/topics/rate-limits#header-format
+func (c *Client) updateRateLimit(route string, header http.Header) {
+	if header.Get("X-RateLimit-Remaining") != "0" {
+		return
+	}
+	resetAfter, err := strconv.ParseFloat(header.Get("X-RateLimit-Reset-After"), 64)
+	if err != nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.blockedRoutes[route] = time.Now().Add(secondsToDuration(resetAfter))
+}
+
+func (c *Client) handleTooManyRequests(route string, header http.Header, body []byte) error {
+	rateLimit := struct {
+		RetryAfter float64 `json:"retry_after"`
+		Global     bool    `json:"global"`
+	}{}
+	if err := json.Unmarshal(body, &rateLimit); err != nil || rateLimit.RetryAfter <= 0 {
+		// fall back to the header, which is given in whole seconds
+		rateLimit.RetryAfter, _ = strconv.ParseFloat(header.Get("Retry-After"), 64)
+		rateLimit.Global = header.Get("X-RateLimit-Global") == "true"
+	}
+	retryAfter := secondsToDuration(rateLimit.RetryAfter)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if rateLimit.Global {
+		c.blockedGlobally = time.Now().Add(retryAfter)
+	} else {
+		c.blockedRoutes[route] = time.Now().Add(retryAfter)
+	}
+	return &RateLimitError{
+		RetryAfter: retryAfter,
+		Global:     rateLimit.Global,
+	}
+}
+
+// routeOf returns the rate limit route of a request, which consists of the method and the path up to the
+// major parameter (channel, guild or webhook), e.g. "POST /webhooks/123".
+func routeOf(method, requestURL string) string {
+	path := requestURL
+	if parsed, err := url.Parse(requestURL); err == nil {
+		path = parsed.Path
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for index, segment := range segments {
+		if (segment == "webhooks" || segment == "channels" || segment == "guilds") && index+1 < len(segments) {
+			return method + " /" + segment + "/" + segments[index+1]
+		}
+	}
+	return method + " " + path
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,70 @@
+package discord
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRouteOf(t *testing.T) {
+	tests := []struct {
+		method string
+		url    string
+		want   string
+	}{
+		{http.MethodPost, DefaultAPIBaseURL + "/webhooks/123/token?wait=true", "POST /webhooks/123"},
+		{http.MethodPatch, DefaultAPIBaseURL + "/webhooks/123/token/messages/456", "PATCH /webhooks/123"},
+		{http.MethodGet, DefaultAPIBaseURL + "/channels/789/messages", "GET /channels/789"},
+		{http.MethodGet, DefaultAPIBaseURL + "/gateway", "GET /api/v10/gateway"},
+	}
+	for _, test := range tests {
+		if got := routeOf(test.method, test.url); got != test.want {
+			t.Errorf("routeOf(%v, %v) = %v, want %v", test.method, test.url, got, test.want)
+		}
+	}
+}
+
+func TestRateLimitBlocksRoute(t *testing.T) {
+	client := NewClient(DefaultAPIBaseURL, "test")
+	header := http.Header{}
+	header.Set("X-RateLimit-Remaining", "0")
+	header.Set("X-RateLimit-Reset-After", "2.5")
+	client.updateRateLimit("POST /webhooks/123", header)
+
+	var rateLimitError *RateLimitError
+	err := client.checkRateLimit("POST /webhooks/123")
+	if !errors.As(err, &rateLimitError) {
+		t.Fatalf("expected a rate limit error, got %v", err)
+	}
+	if rateLimitError.Global || rateLimitError.RetryAfter <= 0 || rateLimitError.RetryAfter > 2500*time.Millisecond {
+		t.Errorf("unexpected rate limit error %+v", rateLimitError)
+	}
+	if err := client.checkRateLimit("POST /webhooks/456"); err != nil {
+		t.Errorf("other routes must not be blocked, got %v", err)
+	}
+}
+
+func TestGlobalRateLimit(t *testing.T) {
+	client := NewClient(DefaultAPIBaseURL, "test")
+	err := client.handleTooManyRequests("POST /webhooks/123", http.Header{}, []byte(`{"message": "You are being rate limited.", "retry_after": 0.5, "global": true}`))
+	var rateLimitError *RateLimitError
+	if !errors.As(err, &rateLimitError) || !rateLimitError.Global {
+		t.Fatalf("expected a global rate limit error, got %v", err)
+	}
+	if err := client.checkRateLimit("GET /channels/1"); !errors.As(err, &rateLimitError) {
+		t.Errorf("all routes must be blocked by a global rate limit, got %v", err)
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	if IsRetryable(&APIError{StatusCode: 404, Code: 10008}) {
+		t.Error("unknown messages must not be retried")
+	}
+	if !IsRetryable(&APIError{StatusCode: 502}) {
+		t.Error("server errors must be retried")
+	}
+	if !IsRetryable(&RateLimitError{RetryAfter: time.Second}) {
+		t.Error("rate limits must be retried")
+	}
+}
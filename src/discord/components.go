@@ -0,0 +1,146 @@
+package discord
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ComponentType defines the type of a message component.
+// https://discord.com/developers/docs/interactions/message-components#component-object-component-types
+type ComponentType int
+
+const (
+	ComponentTypeActionRow         ComponentType = 1
+	ComponentTypeButton            ComponentType = 2
+	ComponentTypeStringSelect      ComponentType = 3
+	ComponentTypeTextInput         ComponentType = 4
+	ComponentTypeUserSelect        ComponentType = 5
+	ComponentTypeRoleSelect        ComponentType = 6
+	ComponentTypeMentionableSelect ComponentType = 7
+	ComponentTypeChannelSelect     ComponentType = 8
+)
+
+// IsSelect reports if the component is one of the select menus.
+func (t ComponentType) IsSelect() bool {
+	switch t {
+	case ComponentTypeStringSelect, ComponentTypeUserSelect, ComponentTypeRoleSelect, ComponentTypeMentionableSelect, ComponentTypeChannelSelect:
+		return true
+	default:
+		return false
+	}
+}
+
+// ComponentStyle defines the style of a button or a text input.
+type ComponentStyle int
+
+// https://discord.com/developers/docs/interactions/message-components#button-object-button-styles
+const (
+	ButtonStylePrimary   ComponentStyle = 1 // Blurple
+	ButtonStyleSecondary ComponentStyle = 2 // Grey
+	ButtonStyleSuccess   ComponentStyle = 3 // Green
+	ButtonStyleDanger    ComponentStyle = 4 // Red
+	// ButtonStyleLink navigates to the URL of the button and has no custom_id
+	ButtonStyleLink ComponentStyle = 5
+)
+
+// https://discord.com/developers/docs/interactions/message-components#text-input-object-text-input-styles
+const (
+	TextInputStyleShort     ComponentStyle = 1
+	TextInputStyleParagraph ComponentStyle = 2
+)
+
+// Component is a message component, i.e. an action row, a button, a select menu or a text input.
+// Which fields are used depends on the type.
+// https://discord.com/developers/docs/interactions/message-components#component-object
+type Component struct {
+	// Type defines the type of the component
+	Type ComponentType `json:"type"`
+	// ID is an optional identifier of the component within the message, which is set by Discord if omitted
+	ID int `json:"id,omitempty"`
+	// CustomID is a developer-defined identifier for the component, max 100 characters.
+	// It is required for all components except action rows and link buttons.
+	CustomID string `json:"custom_id,omitempty"`
+	// Style defines the style of a button or text input
+	Style ComponentStyle `json:"style,omitempty"`
+	// Label is shown on a button or above a text input, max 80 characters (45 for text inputs)
+	Label string `json:"label,omitempty"`
+	// Emoji is shown on a button
+	Emoji *Emoji `json:"emoji,omitempty"`
+	// URL is the target of a link button
+	URL string `json:"url,omitempty"`
+	// Disabled prevents any interaction with a button or select menu
+	Disabled bool `json:"disabled,omitempty"`
+	// Options contains the choices of a string select menu, max 25 options
+	Options []SelectOption `json:"options,omitempty"`
+	// ChannelTypes restricts the channels of a channel select menu
+	ChannelTypes []int `json:"channel_types,omitempty"`
+	// DefaultValues are pre-selected in a user, role, mentionable or channel select menu
+	DefaultValues []SelectDefaultValue `json:"default_values,omitempty"`
+	// Placeholder is shown in an empty text input or select menu, max 150 characters (100 for text inputs)
+	Placeholder string `json:"placeholder,omitempty"`
+	// MinValues is the minimum number of options that must be chosen in a select menu (default 1).
+	// It is a pointer, since 0 is a valid value.
+	MinValues *int `json:"min_values,omitempty"`
+	// MaxValues is the maximum number of options that can be chosen in a select menu (default 1)
+	MaxValues int `json:"max_values,omitempty"`
+	// MinLength is the minimum input length of a text input
+	MinLength int `json:"min_length,omitempty"`
+	// MaxLength is the maximum input length of a text input
+	MaxLength int `json:"max_length,omitempty"`
+	// Required marks a text input as required
+	Required bool `json:"required,omitempty"`
+	// Value is the pre-filled value of a text input or the value submitted by the user
+	Value string `json:"value,omitempty"`
+	// Components contains the children of an action row
+	Components []Component `json:"components,omitempty"`
+}
+
+// Emoji is a custom or unicode emoji shown on a component.
+// For unicode emojis, only the name is set to the emoji itself.
+type Emoji struct {
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Animated bool   `json:"animated,omitempty"`
+}
+
+var customEmojiPattern = regexp.MustCompile(`^<(a?):(\w{2,32}):(\d+)>$`)
+
+// ParseEmoji parses a unicode emoji like "🎲" or a custom emoji in the message format, e.g. "<:dice:123456>"
+// or "<a:dice:123456>" for animated emojis.
+func ParseEmoji(text string) (*Emoji, error) {
+	if match := customEmojiPattern.FindStringSubmatch(text); match != nil {
+		return &Emoji{
+			ID:       match[3],
+			Name:     match[2],
+			Animated: match[1] == "a",
+		}, nil
+	}
+	// Note: unicode emojis can consist of several code points, e.g. keycaps, but never only of ASCII characters
+	if text == "" || strings.ContainsAny(text, " \t\n<>:") || strings.IndexFunc(text, func(r rune) bool { return r > unicode.MaxASCII }) < 0 {
+		return nil, fmt.Errorf("%q is neither a unicode emoji nor a custom emoji like <:name:id>", text)
+	}
+	return &Emoji{Name: text}, nil
+}
+
+// SelectOption is a single choice of a select menu.
+type SelectOption struct {
+	// Label is shown to the user, max 100 characters
+	Label string `json:"label"`
+	// Value is the developer-defined value of the option, max 100 characters
+	Value string `json:"value"`
+	// Description is an additional text shown under the label, max 100 characters
+	Description string `json:"description,omitempty"`
+	// Emoji is shown in front of the label
+	Emoji *Emoji `json:"emoji,omitempty"`
+	// Default marks the option as selected
+	Default bool `json:"default,omitempty"`
+}
+
+// SelectDefaultValue is a pre-selected value of an auto-populated select menu.
+type SelectDefaultValue struct {
+	ID string `json:"id"`
+	// Type is "user", "role" or "channel"
+	Type string `json:"type"`
+}
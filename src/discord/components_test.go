@@ -0,0 +1,28 @@
+package discord
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEmoji(t *testing.T) {
+	valid := map[string]Emoji{
+		"🎲":               {Name: "🎲"},
+		"1️⃣":             {Name: "1️⃣"},
+		"<:dice:123456>":  {ID: "123456", Name: "dice"},
+		"<a:dice:123456>": {ID: "123456", Name: "dice", Animated: true},
+	}
+	for text, expected := range valid {
+		emoji, err := ParseEmoji(text)
+		if err != nil {
+			t.Errorf("could not parse %q: %v", text, err)
+		} else if !reflect.DeepEqual(*emoji, expected) {
+			t.Errorf("expected %+v for %q, got %+v", expected, text, *emoji)
+		}
+	}
+	for _, text := range []string{"", "dice", ":dice:", "<:dice:>", "🎲 🎲"} {
+		if _, err := ParseEmoji(text); err == nil {
+			t.Errorf("expected an error for %q", text)
+		}
+	}
+}
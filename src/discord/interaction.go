@@ -1,35 +1,208 @@
 package discord
 
 import (
-	"crypto/ed25519"
+	"fmt"
 	"net/http"
+)
+
+// MessageFlags is a bit field of message flags.
+// https://discord.com/developers/docs/resources/message#message-object-message-flags
+type MessageFlags int
+
+// MessageFlagEphemeral marks a message as only visible to the user who triggered the interaction.
+const MessageFlagEphemeral MessageFlags = 1 << 6
+
+// InteractionType defines the type of an interaction.
+// https://discord.com/developers/docs/interactions/receiving-and-responding#interaction-object-interaction-type
+type InteractionType int
+
+const (
+	InteractionTypePing                           InteractionType = 1
+	InteractionTypeApplicationCommand             InteractionType = 2
+	InteractionTypeMessageComponent               InteractionType = 3
+	InteractionTypeApplicationCommandAutocomplete InteractionType = 4
+	InteractionTypeModalSubmit                    InteractionType = 5
+)
+
+// Interaction is sent by Discord when a user uses a component or submits a modal.
+// https://discord.com/developers/docs/interactions/receiving-and-responding#interaction-object
+type Interaction struct {
+	ID            string          `json:"id"`
+	ApplicationID string          `json:"application_id"`
+	Type          InteractionType `json:"type"`
+	// Data is nil for pings
+	Data    *InteractionData `json:"data,omitempty"`
+	GuildID string           `json:"guild_id,omitempty"`
+	// ChannelID is the channel the interaction was sent from
+	ChannelID string `json:"channel_id,omitempty"`
+	// Member is set for interactions in a guild
+	Member *Member `json:"member,omitempty"`
+	// User is set for interactions in a direct message
+	User *User `json:"user,omitempty"`
+	// Token is used for follow-up messages and valid for 15 minutes
+	Token   string `json:"token"`
+	Version int    `json:"version"`
+	// Message is the message the used component is attached to.
+	// For modal submits, it is only set if the modal was opened by a component.
+	Message *Message `json:"message,omitempty"`
+	// AppPermissions is the bit field of permissions the application has in the channel
+	AppPermissions string `json:"app_permissions,omitempty"`
+	// Locale is the language of the user, e.g. "en-US", and not set for pings
+	Locale string `json:"locale,omitempty"`
+	// GuildLocale is the preferred language of the guild
+	GuildLocale string `json:"guild_locale,omitempty"`
+
+	// MessageLocale is not sent by Discord, but the language of the texts of the message as chosen by the application
+	MessageLocale string `json:"-"`
+}
+
+// InteractionData contains the data of a component or modal submit interaction.
+// https://discord.com/developers/docs/interactions/receiving-and-responding#interaction-object-message-component-data-structure
+type InteractionData struct {
+	// CustomID is the custom_id of the used component or the submitted modal
+	CustomID string `json:"custom_id,omitempty"`
+	// ComponentType is the type of the used component
+	ComponentType ComponentType `json:"component_type,omitempty"`
+	// Values contains the selected options of a select menu
+	Values []string `json:"values,omitempty"`
+	// Resolved contains the users and members selected in an auto-populated select menu
+	Resolved *ResolvedData `json:"resolved,omitempty"`
+	// Components contains the submitted values of a modal
+	Components []Component `json:"components,omitempty"`
+}
+
+// ResolvedData maps IDs to the objects selected in a select menu.
+type ResolvedData struct {
+	Users map[string]User `json:"users,omitempty"`
+	// Members do not contain the user, which is part of Users instead
+	Members map[string]Member `json:"members,omitempty"`
+}
+
+// User is a Discord user.
+// https://discord.com/developers/docs/resources/user#user-object
+type User struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	// Discriminator is "0" for users that migrated to unique usernames
+	Discriminator string `json:"discriminator"`
+	// GlobalName is the display name of the user, if set
+	GlobalName string `json:"global_name,omitempty"`
+	Avatar     string `json:"avatar,omitempty"`
+	Bot        bool   `json:"bot,omitempty"`
+}
+
+// Member is a user in the context of a guild.
+// https://discord.com/developers/docs/resources/guild#guild-member-object
+type Member struct {
+	User User `json:"user"`
+	// Nick is the nickname of the member in the guild, if set
+	Nick  string   `json:"nick,omitempty"`
+	Roles []string `json:"roles"`
+	// JoinedAt is an ISO8601 timestamp
+	JoinedAt string `json:"joined_at"`
+	Deaf     bool   `json:"deaf"`
+	Mute     bool   `json:"mute"`
+	Pending  bool   `json:"pending,omitempty"`
+	// Permissions is the bit field of the permissions of the member in the channel of the interaction
+	Permissions string `json:"permissions,omitempty"`
+}
 
-	"github.com/bsdlp/discord-interactions-go/interactions"
+// DisplayName returns the name of the user as shown in the guild.
+func (m Member) DisplayName() string {
+	if m.Nick != "" {
+		return m.Nick
+	}
+	if m.User.GlobalName != "" {
+		return m.User.GlobalName
+	}
+	return m.User.Username
+}
+
+// InteractionResponseType defines the type of the response to an interaction.
+// https://discord.com/developers/docs/interactions/receiving-and-responding#interaction-response-object-interaction-callback-type
+type InteractionResponseType int
+
+const (
+	InteractionResponsePong InteractionResponseType = 1
+	// InteractionResponseChannelMessage replies with a new message
+	InteractionResponseChannelMessage InteractionResponseType = 4
+	// InteractionResponseDeferredChannelMessage shows a loading state until a follow-up message is sent
+	InteractionResponseDeferredChannelMessage InteractionResponseType = 5
+	// InteractionResponseDeferredUpdateMessage acknowledges a component interaction without changing the message
+	InteractionResponseDeferredUpdateMessage InteractionResponseType = 6
+	// InteractionResponseUpdateMessage replaces the message the component is attached to
+	InteractionResponseUpdateMessage InteractionResponseType = 7
+	InteractionResponseModal         InteractionResponseType = 9
 )
 
-type ButtonInteraction struct {
-	interactions.Data
-	DataInternal struct {
-		CustomID      string `json:"custom_id"`
-		ComponentType int    `json:"component_type"`
-	} `json:"data"`
-	Message WebhookWithComponent `json:"message"`
+// InteractionResponse is the answer to an interaction.
+type InteractionResponse struct {
+	Type InteractionResponseType `json:"type"`
+	// Data is a message for the response types 4 and 7, a modal for type 9 and empty otherwise
+	Data *InteractionCallbackData `json:"data,omitempty"`
+}
+
+// InteractionCallbackData is the message or modal of an interaction response.
+// https://discord.com/developers/docs/interactions/receiving-and-responding#interaction-response-object-interaction-callback-data-structure
+type InteractionCallbackData struct {
+	WebhookWithComponent
+	// CustomID and Title are only used for modals
+	CustomID string `json:"custom_id,omitempty"`
+	Title    string `json:"title,omitempty"`
+}
+
+// NewMessageResponse creates a response of the given type with the message.
+func NewMessageResponse(responseType InteractionResponseType, message WebhookWithComponent) InteractionResponse {
+	return InteractionResponse{
+		Type: responseType,
+		Data: &InteractionCallbackData{
+			WebhookWithComponent: message,
+		},
+	}
 }
 
-type ButtonInteractionResponse struct {
-	Type int                  `json:"type"`
-	Data WebhookWithComponent `json:"data"`
+// NewModalResponse creates a response that opens the modal.
+func NewModalResponse(modal Modal) InteractionResponse {
+	data := &InteractionCallbackData{
+		CustomID: modal.CustomID,
+		Title:    modal.Title,
+	}
+	data.Components = modal.Components
+	return InteractionResponse{
+		Type: InteractionResponseModal,
+		Data: data,
+	}
 }
 
-// Verify implements the Security and Authorization section of the Discord API.
-// https://discord.com/developers/docs/interactions/slash-commands#security-and-authorization
-func Verify(publicKey []byte, next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		verified := interactions.Verify(r, ed25519.PublicKey(publicKey))
-		if !verified {
-			w.WriteHeader(http.StatusUnauthorized)
-			return
+// Modal is a popup form containing text inputs.
+// https://discord.com/developers/docs/interactions/receiving-and-responding#interaction-response-object-modal
+type Modal struct {
+	// CustomID is a developer-defined identifier for the modal, max 100 characters
+	CustomID string `json:"custom_id"`
+	// Title is shown at the top of the modal, max 45 characters
+	Title string `json:"title"`
+	// Components contains between 1 and 5 action rows with one text input each
+	Components []Component `json:"components"`
+}
+
+// ModalValue returns the value of the text input with the given custom_id from a submitted modal.
+func (i Interaction) ModalValue(customID string) string {
+	for _, row := range i.Data.Components {
+		for _, component := range row.Components {
+			if component.CustomID == customID {
+				return component.Value
+			}
 		}
-		next.ServeHTTP(w, r)
-	})
+	}
+	return ""
+}
+
+// SendFollowupMessage creates a follow-up message for an interaction, after the initial response was sent.
+// The interaction token is valid for 15 minutes.
+func SendFollowupMessage(client *Client, applicationID, interactionToken string, data WebhookWithComponent) error {
+	err := client.Request(http.MethodPost, webhookPath(applicationID, interactionToken), data, nil)
+	if err != nil {
+		return fmt.Errorf("could not send follow-up message: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,242 @@
+package discord
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// recordedInteractions are payloads as sent by Discord, see testdata.
+var recordedInteractions = []string{
+	"ping.json",
+	"button.json",
+	"select.json",
+	"modal_submit.json",
+}
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("could not read testdata: %v", err)
+	}
+	return data
+}
+
+func decodeGeneric(t *testing.T, data []byte) interface{} {
+	t.Helper()
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		t.Fatalf("could not decode JSON: %v", err)
+	}
+	return generic
+}
+
+// TestInteractionRoundTrip checks that decoding and encoding a recorded interaction does not change or add any values.
+// Fields that are not part of the model are dropped.
+func TestInteractionRoundTrip(t *testing.T) {
+	for _, name := range recordedInteractions {
+		t.Run(name, func(t *testing.T) {
+			recorded := readTestdata(t, name)
+			interaction := Interaction{}
+			if err := json.Unmarshal(recorded, &interaction); err != nil {
+				t.Fatalf("could not decode interaction: %v", err)
+			}
+			encoded, err := json.Marshal(interaction)
+			if err != nil {
+				t.Fatalf("could not encode interaction: %v", err)
+			}
+
+			original := decodeGeneric(t, recorded)
+			if path, ok := isSubset(decodeGeneric(t, encoded), original, "$"); !ok {
+				t.Errorf("encoded interaction differs from the recorded one at %v:\n%s", path, encoded)
+			}
+
+			decodedAgain := Interaction{}
+			if err := json.Unmarshal(encoded, &decodedAgain); err != nil {
+				t.Fatalf("could not decode encoded interaction: %v", err)
+			}
+			encodedAgain, err := json.Marshal(decodedAgain)
+			if err != nil {
+				t.Fatalf("could not encode interaction: %v", err)
+			}
+			if string(encoded) != string(encodedAgain) {
+				t.Errorf("interaction changed after encoding and decoding again:\n%s\n%s", encoded, encodedAgain)
+			}
+		})
+	}
+}
+
+// TestComponentRoundTrip checks that the component model contains all fields of the recorded components.
+func TestComponentRoundTrip(t *testing.T) {
+	for _, name := range recordedInteractions {
+		t.Run(name, func(t *testing.T) {
+			recorded := struct {
+				Message *struct {
+					Components json.RawMessage `json:"components"`
+				} `json:"message"`
+			}{}
+			if err := json.Unmarshal(readTestdata(t, name), &recorded); err != nil {
+				t.Fatalf("could not decode interaction: %v", err)
+			}
+			if recorded.Message == nil {
+				return
+			}
+			components := []Component{}
+			if err := json.Unmarshal(recorded.Message.Components, &components); err != nil {
+				t.Fatalf("could not decode components: %v", err)
+			}
+			encoded, err := json.Marshal(components)
+			if err != nil {
+				t.Fatalf("could not encode components: %v", err)
+			}
+			expected := withoutEmptyValues(decodeGeneric(t, recorded.Message.Components))
+			actual := withoutEmptyValues(decodeGeneric(t, encoded))
+			if !reflect.DeepEqual(expected, actual) {
+				t.Errorf("components changed after decoding and encoding:\n%s\n%s", recorded.Message.Components, encoded)
+			}
+		})
+	}
+}
+
+func TestInteractionDecoding(t *testing.T) {
+	interaction := Interaction{}
+	if err := json.Unmarshal(readTestdata(t, "select.json"), &interaction); err != nil {
+		t.Fatalf("could not decode interaction: %v", err)
+	}
+	if interaction.Type != InteractionTypeMessageComponent || interaction.Data.ComponentType != ComponentTypeStringSelect {
+		t.Errorf("unexpected types %v and %v", interaction.Type, interaction.Data.ComponentType)
+	}
+	if len(interaction.Data.Values) != 1 || interaction.Data.Values[0] != "846600000000000002" {
+		t.Errorf("unexpected values %v", interaction.Data.Values)
+	}
+	kickSelect := interaction.Message.Components[0].Components[0]
+	if kickSelect.MinValues == nil || *kickSelect.MinValues != 0 {
+		t.Errorf("expected min_values of 0 to be kept, got %v", kickSelect.MinValues)
+	}
+	if !kickSelect.Type.IsSelect() || !interaction.Message.Components[1].Components[1].Type.IsSelect() {
+		t.Errorf("expected select menus")
+	}
+
+	if err := json.Unmarshal(readTestdata(t, "modal_submit.json"), &interaction); err != nil {
+		t.Fatalf("could not decode interaction: %v", err)
+	}
+	if note := interaction.ModalValue("note"); note != "Running late, 20 minutes" {
+		t.Errorf("unexpected note %q", note)
+	}
+	if name := interaction.Member.DisplayName(); name != "other" {
+		t.Errorf("unexpected display name %q", name)
+	}
+}
+
+func TestInteractionResponseEncoding(t *testing.T) {
+	noteInput := Component{
+		Type:        ComponentTypeTextInput,
+		CustomID:    "note",
+		Style:       TextInputStyleShort,
+		Label:       "Note",
+		Placeholder: "e.g. running late",
+		MaxLength:   100,
+	}
+	modal := NewModalResponse(Modal{
+		CustomID: "v1:submit_note",
+		Title:    "Note for Test-Event",
+		Components: []Component{
+			{
+				Type:       ComponentTypeActionRow,
+				Components: []Component{noteInput},
+			},
+		},
+	})
+	ephemeral := WebhookWithComponent{
+		Flags: MessageFlagEphemeral,
+	}
+	ephemeral.Content = "You are already signed up for Game1."
+
+	tests := map[string]InteractionResponse{
+		"response_modal.json":     modal,
+		"response_ephemeral.json": NewMessageResponse(InteractionResponseChannelMessage, ephemeral),
+	}
+	for name, response := range tests {
+		encoded, err := json.Marshal(response)
+		if err != nil {
+			t.Fatalf("could not encode response: %v", err)
+		}
+		if !reflect.DeepEqual(decodeGeneric(t, encoded), decodeGeneric(t, readTestdata(t, name))) {
+			t.Errorf("response differs from %v:\n%s", name, encoded)
+		}
+	}
+}
+
+// isSubset reports if all values of actual are contained in expected and returns the path of the first difference.
+func isSubset(actual, expected interface{}, path string) (string, bool) {
+	switch actual := actual.(type) {
+	case map[string]interface{}:
+		expectedMap, ok := expected.(map[string]interface{})
+		if !ok {
+			return path, false
+		}
+		for key, value := range actual {
+			if subPath, ok := isSubset(value, expectedMap[key], path+"."+key); !ok {
+				return subPath, false
+			}
+		}
+		return "", true
+	case []interface{}:
+		expectedSlice, ok := expected.([]interface{})
+		if !ok || len(actual) != len(expectedSlice) {
+			return path, false
+		}
+		for i := range actual {
+			if subPath, ok := isSubset(actual[i], expectedSlice[i], fmt.Sprintf("%v[%v]", path, i)); !ok {
+				return subPath, false
+			}
+		}
+		return "", true
+	default:
+		return path, reflect.DeepEqual(actual, expected)
+	}
+}
+
+// withoutEmptyValues removes null, false, empty strings and empty collections, which are omitted when encoding.
+func withoutEmptyValues(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{})
+		for key, child := range value {
+			child = withoutEmptyValues(child)
+			if !isEmptyValue(child) {
+				result[key] = child
+			}
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, 0, len(value))
+		for _, child := range value {
+			result = append(result, withoutEmptyValues(child))
+		}
+		return result
+	default:
+		return value
+	}
+}
+
+func isEmptyValue(value interface{}) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case bool:
+		return !value
+	case string:
+		return value == ""
+	case map[string]interface{}:
+		return len(value) == 0
+	case []interface{}:
+		return len(value) == 0
+	default:
+		return false
+	}
+}
@@ -0,0 +1,107 @@
+package discord
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ScheduledEventStatus is the status of a Guild Scheduled Event.
+// https://discord.com/developers/docs/resources/guild-scheduled-event#guild-scheduled-event-object-guild-scheduled-event-status
+type ScheduledEventStatus int
+
+const (
+	ScheduledEventStatusScheduled ScheduledEventStatus = 1
+	ScheduledEventStatusActive    ScheduledEventStatus = 2
+	ScheduledEventStatusCompleted ScheduledEventStatus = 3
+	ScheduledEventStatusCanceled  ScheduledEventStatus = 4
+)
+
+// ScheduledEventEntityTypeExternal is the entity type of events that do not take place in a voice or stage channel.
+// They require a location and an end time.
+const ScheduledEventEntityTypeExternal = 3
+
+// scheduledEventPrivacyLevelGuildOnly is the only privacy level supported by Discord
+const scheduledEventPrivacyLevelGuildOnly = 2
+
+// GuildScheduledEvent is an event shown in the Events tab of a guild.
+// https://discord.com/developers/docs/resources/guild-scheduled-event#guild-scheduled-event-object
+type GuildScheduledEvent struct {
+	ID                 string               `json:"id,omitempty"`
+	GuildID            string               `json:"guild_id,omitempty"`
+	Name               string               `json:"name"`
+	Description        string               `json:"description,omitempty"`
+	ScheduledStartTime time.Time            `json:"scheduled_start_time"`
+	ScheduledEndTime   time.Time            `json:"scheduled_end_time"`
+	PrivacyLevel       int                  `json:"privacy_level"`
+	Status             ScheduledEventStatus `json:"status,omitempty"`
+	EntityType         int                  `json:"entity_type"`
+	EntityMetadata     struct {
+		// Location is shown instead of a channel for external events, max 100 characters
+		Location string `json:"location"`
+	} `json:"entity_metadata"`
+}
+
+// NewExternalScheduledEvent creates an event with the given location, that is not bound to a channel.
+func NewExternalScheduledEvent(name, description, location string, startsAt, endsAt time.Time) GuildScheduledEvent {
+	event := GuildScheduledEvent{
+		Name:               name,
+		Description:        description,
+		ScheduledStartTime: startsAt,
+		ScheduledEndTime:   endsAt,
+		PrivacyLevel:       scheduledEventPrivacyLevelGuildOnly,
+		EntityType:         ScheduledEventEntityTypeExternal,
+	}
+	event.EntityMetadata.Location = location
+	return event
+}
+
+// ScheduledEventURL returns the link to the event, which opens it in the Events tab.
+func ScheduledEventURL(guildID, eventID string) string {
+	return fmt.Sprintf("https://discord.com/events/%v/%v", guildID, eventID)
+}
+
+// CreateGuildScheduledEvent creates the event in the guild. The client requires a bot token with the Manage Events permission.
+func CreateGuildScheduledEvent(client *Client, guildID string, event GuildScheduledEvent) (GuildScheduledEvent, error) {
+	created := GuildScheduledEvent{}
+	err := client.Request(http.MethodPost, scheduledEventsPath(guildID), event, &created)
+	if err != nil {
+		return GuildScheduledEvent{}, fmt.Errorf("could not create scheduled event: %w", err)
+	}
+	return created, nil
+}
+
+// ModifyGuildScheduledEvent replaces the name, description, times and location of the event.
+func ModifyGuildScheduledEvent(client *Client, guildID, eventID string, event GuildScheduledEvent) error {
+	err := client.Request(http.MethodPatch, scheduledEventsPath(guildID)+"/"+eventID, event, nil)
+	if err != nil {
+		return fmt.Errorf("could not modify scheduled event: %w", err)
+	}
+	return nil
+}
+
+// CancelGuildScheduledEvent sets the status of the event to canceled. Only scheduled events can be canceled.
+func CancelGuildScheduledEvent(client *Client, guildID, eventID string) error {
+	data := struct {
+		Status ScheduledEventStatus `json:"status"`
+	}{
+		Status: ScheduledEventStatusCanceled,
+	}
+	err := client.Request(http.MethodPatch, scheduledEventsPath(guildID)+"/"+eventID, data, nil)
+	if err != nil {
+		return fmt.Errorf("could not cancel scheduled event: %w", err)
+	}
+	return nil
+}
+
+func DeleteGuildScheduledEvent(client *Client, guildID, eventID string) error {
+	err := client.Request(http.MethodDelete, scheduledEventsPath(guildID)+"/"+eventID, nil, nil)
+	if err != nil {
+		return fmt.Errorf("could not delete scheduled event: %w", err)
+	}
+	return nil
+}
+
+func scheduledEventsPath(guildID string) string {
+	return "/guilds/" + guildID + "/scheduled-events"
+}
@@ -0,0 +1,43 @@
+package discord
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimestampStyle defines how a timestamp in a message is shown by the Discord client.
+// https://discord.com/developers/docs/reference#message-formatting-timestamp-styles
+type TimestampStyle string
+
+const (
+	// TimestampStyleShortTime is shown as e.g. "16:20"
+	TimestampStyleShortTime TimestampStyle = "t"
+	// TimestampStyleLongTime is shown as e.g. "16:20:30"
+	TimestampStyleLongTime TimestampStyle = "T"
+	// TimestampStyleShortDate is shown as e.g. "20/04/2021"
+	TimestampStyleShortDate TimestampStyle = "d"
+	// TimestampStyleLongDate is shown as e.g. "20 April 2021"
+	TimestampStyleLongDate TimestampStyle = "D"
+	// TimestampStyleShortDateTime is shown as e.g. "20 April 2021 16:20"
+	TimestampStyleShortDateTime TimestampStyle = "f"
+	// TimestampStyleLongDateTime is shown as e.g. "Tuesday, 20 April 2021 16:20"
+	TimestampStyleLongDateTime TimestampStyle = "F"
+	// TimestampStyleRelative is shown as e.g. "in 2 hours" and updated by the client
+	TimestampStyleRelative TimestampStyle = "R"
+)
+
+// Valid reports if the style is known to Discord.
+func (s TimestampStyle) Valid() bool {
+	switch s {
+	case TimestampStyleShortTime, TimestampStyleLongTime, TimestampStyleShortDate, TimestampStyleLongDate,
+		TimestampStyleShortDateTime, TimestampStyleLongDateTime, TimestampStyleRelative:
+		return true
+	default:
+		return false
+	}
+}
+
+// FormatTimestamp returns the markup for a timestamp, which every client shows in the time zone and language of its user.
+func FormatTimestamp(t time.Time, style TimestampStyle) string {
+	return fmt.Sprintf("<t:%v:%v>", t.Unix(), style)
+}
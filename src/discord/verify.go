@@ -0,0 +1,141 @@
+package discord
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bsdlp/discord-interactions-go/interactions"
+)
+
+// DefaultMaxTimestampSkew is the maximum difference between the signature timestamp of a request and the local time,
+// if nothing else is configured.
+const DefaultMaxTimestampSkew = 5 * time.Minute
+
+// Verifier implements the Security and Authorization section of the Discord API.
+// https://discord.com/developers/docs/interactions/slash-commands#security-and-authorization
+// Additionally, it rejects requests with outdated timestamps and requests for interactions that were already received,
+// so that captured requests can not be replayed.
+type Verifier struct {
+	publicKey ed25519.PublicKey
+	maxSkew   time.Duration
+
+	// mutex guards seenIDs
+	mutex sync.Mutex
+	// seenIDs maps the IDs of received interactions to the time they were received
+	seenIDs map[string]time.Time
+
+	invalidSignatures uint64
+	staleTimestamps   uint64
+	replays           uint64
+}
+
+// VerifierCounters contains the number of requests rejected by a Verifier, grouped by reason.
+type VerifierCounters struct {
+	InvalidSignatures uint64 `json:"invalid_signatures"`
+	StaleTimestamps   uint64 `json:"stale_timestamps"`
+	Replays           uint64 `json:"replays"`
+}
+
+// NewVerifier creates a Verifier for the ed25519 public key of the application.
+// Requests whose timestamp differs by more than maxSkew from the local time are rejected.
+func NewVerifier(publicKey []byte, maxSkew time.Duration) *Verifier {
+	return &Verifier{
+		publicKey: ed25519.PublicKey(publicKey),
+		maxSkew:   maxSkew,
+		seenIDs:   make(map[string]time.Time),
+	}
+}
+
+// Verify is a shorthand for a Verifier with the DefaultMaxTimestampSkew.
+func Verify(publicKey []byte, next http.Handler) http.Handler {
+	return NewVerifier(publicKey, DefaultMaxTimestampSkew).Verify(next)
+}
+
+// Verify only calls next for requests with a valid signature, a recent timestamp and a new interaction ID.
+func (v *Verifier) Verify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verified := interactions.Verify(r, v.publicKey)
+		if !verified {
+			atomic.AddUint64(&v.invalidSignatures, 1)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		// Note: the timestamp is part of the signed message, so it can not be changed without invalidating the signature
+		now := time.Now()
+		timestamp, err := strconv.ParseInt(r.Header.Get("X-Signature-Timestamp"), 10, 64)
+		if err != nil || absDuration(now.Sub(time.Unix(timestamp, 0))) > v.maxSkew {
+			atomic.AddUint64(&v.staleTimestamps, 1)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		interactionID, err := peekInteractionID(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !v.markAsSeen(interactionID, now) {
+			atomic.AddUint64(&v.replays, 1)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Counters returns the number of rejected requests.
+func (v *Verifier) Counters() VerifierCounters {
+	return VerifierCounters{
+		InvalidSignatures: atomic.LoadUint64(&v.invalidSignatures),
+		StaleTimestamps:   atomic.LoadUint64(&v.staleTimestamps),
+		Replays:           atomic.LoadUint64(&v.replays),
+	}
+}
+
+// markAsSeen records the interaction ID and returns false, if it was already recorded.
+// Since requests outside of the skew window are rejected anyway, IDs are forgotten after twice the window.
+func (v *Verifier) markAsSeen(interactionID string, now time.Time) bool {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	for id, seenAt := range v.seenIDs {
+		if now.Sub(seenAt) > 2*v.maxSkew {
+			delete(v.seenIDs, id)
+		}
+	}
+	if _, ok := v.seenIDs[interactionID]; ok {
+		return false
+	}
+	v.seenIDs[interactionID] = now
+	return true
+}
+
+// peekInteractionID reads the ID of the interaction from the body, which stays readable for the next handler.
+func peekInteractionID(r *http.Request) (string, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "", err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	interaction := struct {
+		ID string `json:"id"`
+	}{}
+	err = json.Unmarshal(body, &interaction)
+	return interaction.ID, err
+}
+
+func absDuration(duration time.Duration) time.Duration {
+	if duration < 0 {
+		return -duration
+	}
+	return duration
+}
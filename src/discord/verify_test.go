@@ -0,0 +1,92 @@
+package discord
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// signedRequest creates a request for the interaction with the ID, signed with the private key at the timestamp.
+func signedRequest(privateKey ed25519.PrivateKey, interactionID string, timestamp time.Time) *http.Request {
+	body := []byte(`{"id":"` + interactionID + `","type":1}`)
+	timestampStr := strconv.FormatInt(timestamp.Unix(), 10)
+	signature := ed25519.Sign(privateKey, append([]byte(timestampStr), body...))
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	r.Header.Set("X-Signature-Ed25519", hex.EncodeToString(signature))
+	r.Header.Set("X-Signature-Timestamp", timestampStr)
+	return r
+}
+
+func TestVerifier(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := NewVerifier(publicKey, time.Minute)
+	handler := verifier.Verify(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the body must still be readable after the verification
+		body, _ := io.ReadAll(r.Body)
+		if !bytes.Contains(body, []byte(`"id"`)) {
+			t.Errorf("expected the body of the interaction, got %q", body)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name     string
+		request  *http.Request
+		expected int
+	}{
+		{"valid", signedRequest(privateKey, "1", time.Now()), http.StatusOK},
+		{"replayed", signedRequest(privateKey, "1", time.Now()), http.StatusUnauthorized},
+		{"other key", signedRequest(otherPrivateKey, "2", time.Now()), http.StatusUnauthorized},
+		{"outdated", signedRequest(privateKey, "3", time.Now().Add(-2*time.Minute)), http.StatusUnauthorized},
+		{"in the future", signedRequest(privateKey, "4", time.Now().Add(2*time.Minute)), http.StatusUnauthorized},
+		{"within the skew", signedRequest(privateKey, "5", time.Now().Add(-30*time.Second)), http.StatusOK},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, test.request)
+		if recorder.Code != test.expected {
+			t.Errorf("%v: expected status code %v, got %v", test.name, test.expected, recorder.Code)
+		}
+	}
+
+	expected := VerifierCounters{
+		InvalidSignatures: 1,
+		StaleTimestamps:   2,
+		Replays:           1,
+	}
+	if counters := verifier.Counters(); counters != expected {
+		t.Errorf("expected counters %+v, got %+v", expected, counters)
+	}
+}
+
+func TestVerifierForgetsOldInteractionIDs(t *testing.T) {
+	verifier := NewVerifier(nil, time.Minute)
+	now := time.Now()
+	if !verifier.markAsSeen("1", now) {
+		t.Fatalf("expected a new interaction ID to be accepted")
+	}
+	if verifier.markAsSeen("1", now.Add(time.Minute)) {
+		t.Errorf("expected a known interaction ID to be rejected")
+	}
+	// requests with the ID would be rejected because of their timestamp anyway
+	if !verifier.markAsSeen("2", now.Add(3*time.Minute)) {
+		t.Fatalf("expected a new interaction ID to be accepted")
+	}
+	if _, ok := verifier.seenIDs["1"]; ok {
+		t.Errorf("expected the ID to be forgotten after twice the skew")
+	}
+}
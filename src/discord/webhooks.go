@@ -1,57 +1,84 @@
 package discord
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 
 	"github.com/bwmarrin/discordgo"
 )
 
+// WebhookWithComponent contains the content of a message that is sent or edited via a webhook.
 type WebhookWithComponent struct {
 	discordgo.WebhookParams
+	// Flags can be set to MessageFlagEphemeral for interaction responses and follow-up messages
+	Flags MessageFlags `json:"flags,omitempty"`
 	// Components contains optional interactive components
 	Components []Component `json:"components,omitempty"`
 }
 
-type Component struct {
-	// Type defines the type of the component. Can be 1 (ActionRow) or 2 (Button)
-	Type int `json:"type"`
-	// Label is used to label the component
-	Label string `json:"label,omitempty"`
-	// Style defines the type of button, if type = 2.
-	// Note that certain styles require other fields, see
-	// https://discord.com/developers/docs/interactions/message-components#buttons-button-styles
-	Style int `json:"style,omitempty"`
-	// CustomID is a developer-defined identifier for the button, max 100 characters
-	CustomID string `json:"custom_id,omitempty"`
-	// Components contains an optional list of child components
-	Components []Component `json:"components,omitempty"`
-}
+func SendWebhookWithComponents(client *Client, webhookID, token string, wait bool, data WebhookWithComponent) (*Message, error) {
+	path := webhookPath(webhookID, token)
 
-func SendWebhookWithComponents(session *discordgo.Session, webhookID, token string, wait bool, data WebhookWithComponent) (*discordgo.Message, error) {
-	uri := discordgo.EndpointWebhookToken(webhookID, token)
-
-	if wait {
-		uri += "?wait=true"
+	if !wait {
+		err := client.Request(http.MethodPost, path, data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("could not send webhook message: %w", err)
+		}
+		return nil, nil
 	}
 
-	response, err := session.RequestWithBucketID(http.MethodPost, uri, data, discordgo.EndpointWebhookToken("", ""))
-	if !wait || err != nil {
-		return nil, fmt.Errorf("could not make request: %w", err)
+	st := &Message{}
+	err := client.Request(http.MethodPost, path+"?wait=true", data, st)
+	if err != nil {
+		return nil, fmt.Errorf("could not send webhook message: %w", err)
 	}
+	return st, nil
+}
 
-	st := &discordgo.Message{}
-	err = json.Unmarshal(response, &st)
-	return st, err
+// GetWebhookMessage returns the content, embeds and components of a message previously sent by the webhook.
+func GetWebhookMessage(client *Client, webhookID, token, messageID string) (WebhookWithComponent, error) {
+	message := Message{}
+	err := client.Request(http.MethodGet, webhookPath(webhookID, token)+"/messages/"+messageID, nil, &message)
+	if err != nil {
+		return WebhookWithComponent{}, fmt.Errorf("could not get webhook message: %w", err)
+	}
+	return message.WebhookWithComponent, nil
 }
 
-func DeleteWebhookMessage(session *discordgo.Session, webhookID, token, messageID string) error {
-	uri := discordgo.EndpointWebhookToken(webhookID, token) + "/messages/" + messageID
+// EditWebhookMessage replaces the content, embeds and components of a message previously sent by the webhook.
+// For interactions, the application ID and interaction token can be used together with the message ID "@original".
+// The edited message is returned.
+func EditWebhookMessage(client *Client, webhookID, token, messageID string, data WebhookWithComponent) (*Message, error) {
+	message := &Message{}
+	err := client.Request(http.MethodPatch, webhookPath(webhookID, token)+"/messages/"+messageID, data, message)
+	if err != nil {
+		return nil, fmt.Errorf("could not edit webhook message: %w", err)
+	}
+	return message, nil
+}
 
-	_, err := session.RequestWithBucketID(http.MethodDelete, uri, nil, discordgo.EndpointWebhookToken("", ""))
+func DeleteWebhookMessage(client *Client, webhookID, token, messageID string) error {
+	err := client.Request(http.MethodDelete, webhookPath(webhookID, token)+"/messages/"+messageID, nil, nil)
 	if err != nil {
 		return fmt.Errorf("could not delete webhook message: %w", err)
 	}
 	return nil
 }
+
+// Message is a message as returned by Discord, e.g. after sending it or as part of an interaction.
+// https://discord.com/developers/docs/resources/message#message-object
+type Message struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id,omitempty"`
+	// WebhookID is set, if the message was sent by a webhook
+	WebhookID     string `json:"webhook_id,omitempty"`
+	ApplicationID string `json:"application_id,omitempty"`
+	Timestamp     string `json:"timestamp,omitempty"`
+	// EditedTimestamp is the time of the last edit of the message and empty, if it was never edited
+	EditedTimestamp string `json:"edited_timestamp,omitempty"`
+	WebhookWithComponent
+}
+
+func webhookPath(webhookID, token string) string {
+	return "/webhooks/" + webhookID + "/" + token
+}
@@ -0,0 +1,204 @@
+// Package discordtest provides utilities for testing the handling of Discord interactions.
+// It creates interactions as Discord would send them and signs them with a generated key pair,
+// so that they pass the same verification as real requests.
+package discordtest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/localthomas/discord-rsvp/discord"
+)
+
+// ApplicationID is the ID of the application used for all created interactions.
+const ApplicationID = "846400000000000001"
+
+// GuildID is the ID of the guild used for all created interactions.
+const GuildID = "846400000000000002"
+
+// ChannelID is the ID of the channel used for all created interactions.
+const ChannelID = "846400000000000003"
+
+// snowflakeCounter ensures unique IDs for interactions and messages
+var snowflakeCounter uint64 = 846500000000000000
+
+// NewSnowflake returns a new unique ID in the format used by Discord.
+func NewSnowflake() string {
+	return strconv.FormatUint(atomic.AddUint64(&snowflakeCounter, 1), 10)
+}
+
+// Signer signs requests the way Discord does, with a key pair generated for the test.
+type Signer struct {
+	PublicKey  ed25519.PublicKey
+	privateKey ed25519.PrivateKey
+}
+
+// NewSigner generates a new ed25519 key pair.
+func NewSigner() *Signer {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(fmt.Sprintf("could not generate key pair: %v", err))
+	}
+	return &Signer{
+		PublicKey:  publicKey,
+		privateKey: privateKey,
+	}
+}
+
+// NewRequest creates a signed POST request with the body and the given signature timestamp.
+func (s *Signer) NewRequest(body []byte, timestamp time.Time) *http.Request {
+	timestampStr := strconv.FormatInt(timestamp.Unix(), 10)
+	signature := ed25519.Sign(s.privateKey, append([]byte(timestampStr), body...))
+
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Signature-Ed25519", hex.EncodeToString(signature))
+	r.Header.Set("X-Signature-Timestamp", timestampStr)
+	return r
+}
+
+// Send signs the interaction with the current time and passes it to the handler.
+func (s *Signer) Send(handler http.Handler, interaction discord.Interaction) *httptest.ResponseRecorder {
+	return SendRequest(handler, s.NewRequest(Marshal(interaction), time.Now()))
+}
+
+// SendRequest passes the request to the handler and records the response.
+func SendRequest(handler http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, r)
+	return recorder
+}
+
+// Marshal encodes the interaction as JSON.
+func Marshal(interaction discord.Interaction) []byte {
+	data, err := json.Marshal(interaction)
+	if err != nil {
+		panic(fmt.Sprintf("could not marshal interaction: %v", err))
+	}
+	return data
+}
+
+// PingInteraction creates the interaction Discord sends to check the endpoint.
+func PingInteraction() discord.Interaction {
+	id := NewSnowflake()
+	return discord.Interaction{
+		ID:            id,
+		ApplicationID: ApplicationID,
+		Type:          discord.InteractionTypePing,
+		Token:         "ping-token-" + id,
+		Version:       1,
+	}
+}
+
+// ButtonInteraction creates the interaction for the button with the custom_id below the message,
+// that was pressed by the user.
+func ButtonInteraction(customID, userID string, message discord.Message) discord.Interaction {
+	id := NewSnowflake()
+	return discord.Interaction{
+		ID:            id,
+		ApplicationID: ApplicationID,
+		Type:          discord.InteractionTypeMessageComponent,
+		Data: &discord.InteractionData{
+			CustomID:      customID,
+			ComponentType: discord.ComponentTypeButton,
+		},
+		GuildID:   GuildID,
+		ChannelID: ChannelID,
+		Member: &discord.Member{
+			User: discord.User{
+				ID:            userID,
+				Username:      "user-" + userID,
+				Discriminator: "0",
+			},
+			Roles:       []string{},
+			JoinedAt:    "2021-06-01T12:00:00.000000+00:00",
+			Permissions: "0",
+		},
+		Token:   "interaction-token-" + id,
+		Version: 1,
+		Message: &message,
+		Locale:  "en-US",
+	}
+}
+
+// SelectInteraction creates the interaction for the select menu with the custom_id below the message,
+// in which the user selected the values.
+func SelectInteraction(customID, userID string, values []string, message discord.Message) discord.Interaction {
+	interaction := ButtonInteraction(customID, userID, message)
+	interaction.Data.ComponentType = discord.ComponentTypeStringSelect
+	interaction.Data.Values = values
+	return interaction
+}
+
+// ModalSubmitInteraction creates the interaction for the modal with the custom_id, that was opened from the message
+// and submitted by the user with the values of its text inputs, keyed by their custom_id.
+func ModalSubmitInteraction(customID, userID string, values map[string]string, message discord.Message) discord.Interaction {
+	interaction := ButtonInteraction(customID, userID, message)
+	interaction.Type = discord.InteractionTypeModalSubmit
+	interaction.Data.ComponentType = 0
+	for inputID, value := range values {
+		interaction.Data.Components = append(interaction.Data.Components, discord.Component{
+			Type: discord.ComponentTypeActionRow,
+			Components: []discord.Component{
+				{
+					Type:     discord.ComponentTypeTextInput,
+					CustomID: inputID,
+					Value:    value,
+				},
+			},
+		})
+	}
+	return interaction
+}
+
+// EventMessage creates a message with an embed for the event and one button per custom_id.
+func EventMessage(title string, customIDs ...string) discord.Message {
+	buttons := make([]discord.Component, 0, len(customIDs))
+	for _, customID := range customIDs {
+		buttons = append(buttons, discord.Component{
+			Type:     discord.ComponentTypeButton,
+			Label:    customID,
+			Style:    discord.ButtonStyleSecondary,
+			CustomID: customID,
+		})
+	}
+	message := discord.Message{
+		ID: NewSnowflake(),
+	}
+	message.Embeds = []*discordgo.MessageEmbed{
+		{
+			Title:       title,
+			Description: "Select the games you want to play via the buttons below.",
+			Color:       0x01579b,
+		},
+	}
+	if len(buttons) > 0 {
+		message.Components = []discord.Component{
+			{
+				Type:       discord.ComponentTypeActionRow,
+				Components: buttons,
+			},
+		}
+	}
+	return message
+}
+
+// DecodeResponse decodes the recorded response to an interaction.
+func DecodeResponse(recorder *httptest.ResponseRecorder) (discord.InteractionResponse, error) {
+	response := discord.InteractionResponse{}
+	err := json.NewDecoder(recorder.Body).Decode(&response)
+	if err != nil {
+		return discord.InteractionResponse{}, fmt.Errorf("could not decode interaction response: %w", err)
+	}
+	return response, nil
+}
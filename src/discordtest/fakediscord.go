@@ -0,0 +1,800 @@
+package discordtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/localthomas/discord-rsvp/discord"
+)
+
+// apiPrefix is the path of the API on the fake server, see FakeDiscord.BaseURL.
+const apiPrefix = "/api/v10"
+
+// FakeDiscord is an in-process server that implements the parts of the API of Discord used by this software:
+// the OAuth2 token endpoint, executing, getting, editing and deleting webhook messages
+// posting, getting, editing and deleting messages of the bot user in the channel ChannelID and its threads,
+// starting and archiving threads, managing the Guild Scheduled Events of the guild GuildID
+// and sending follow-up messages to and editing the original message of interactions, that were passed through InteractionEndpoint.
+// Errors are answered with the same status codes and JSON error codes as Discord uses.
+type FakeDiscord struct {
+	// ClientID and ClientSecret are the credentials of the application, that are accepted by the token endpoint
+	ClientID     string
+	ClientSecret string
+	// BotToken is the token of the bot user, that is required for requests to guilds
+	BotToken string
+
+	server *httptest.Server
+
+	// mutex guards the fields below
+	mutex sync.Mutex
+	// webhooks maps webhook IDs to their tokens
+	webhooks map[string]string
+	// codes maps authorization codes to their redirect URI
+	codes map[string]string
+	// refreshTokens contains all refresh tokens that were issued and not used yet
+	refreshTokens map[string]bool
+	messages      []FakeMessage
+	// scheduledEvents contains the Guild Scheduled Events in the order they were created
+	scheduledEvents []discord.GuildScheduledEvent
+	// threads contains the threads started on messages in the order they were started
+	threads []FakeThread
+	// interactions maps the tokens of the interactions, that were responded to, to their original message
+	interactions map[string]discord.WebhookWithComponent
+	// followups contains the follow-up messages to interactions in the order they were sent
+	followups []FakeMessage
+	// rateLimits are answered to the next requests instead of handling them
+	rateLimits []fakeRateLimit
+	requests   []string
+}
+
+// FakeMessage is a message sent by a webhook or posted by the bot user to a channel of the fake server.
+type FakeMessage struct {
+	ID        string
+	ChannelID string
+	// WebhookID is empty for messages of the bot user
+	WebhookID string
+	// EditedTimestamp is set by every edit of the message
+	EditedTimestamp string
+	discord.WebhookWithComponent
+}
+
+// FakeThread is a public thread started on a message of the channel ChannelID.
+type FakeThread struct {
+	discord.Channel
+	// MessageID is the message the thread was started on, which has the same ID as the thread on Discord
+	MessageID string
+	Archived  bool
+	Locked    bool
+}
+
+type fakeRateLimit struct {
+	retryAfter time.Duration
+	global     bool
+}
+
+// NewFakeDiscord starts a new fake server, which must be closed after use.
+func NewFakeDiscord() *FakeDiscord {
+	fake := &FakeDiscord{
+		ClientID:      NewSnowflake(),
+		ClientSecret:  "secret-" + NewSnowflake(),
+		BotToken:      "bot-token-" + NewSnowflake(),
+		webhooks:      make(map[string]string),
+		codes:         make(map[string]string),
+		refreshTokens: make(map[string]bool),
+		interactions:  make(map[string]discord.WebhookWithComponent),
+	}
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	return fake
+}
+
+// Close shuts down the server.
+func (f *FakeDiscord) Close() {
+	f.server.Close()
+}
+
+// BaseURL returns the base URL of the API, which can be passed to discord.NewClient.
+func (f *FakeDiscord) BaseURL() string {
+	return f.server.URL + apiPrefix
+}
+
+// AddWebhook creates a new webhook and returns its ID and token.
+func (f *FakeDiscord) AddWebhook() (string, string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	id := NewSnowflake()
+	token := "webhook-token-" + id
+	f.webhooks[id] = token
+	return id, token
+}
+
+// AuthorizationCode returns a code, as if a user authorized the application with the given redirect URI.
+// Exchanging the code for a token creates a new webhook.
+func (f *FakeDiscord) AuthorizationCode(redirectURI string) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	code := "code-" + NewSnowflake()
+	f.codes[code] = redirectURI
+	return code
+}
+
+// RateLimitNext answers the next request with status 429 and the given retry_after.
+// Multiple calls rate limit multiple requests.
+func (f *FakeDiscord) RateLimitNext(retryAfter time.Duration, global bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.rateLimits = append(f.rateLimits, fakeRateLimit{
+		retryAfter: retryAfter,
+		global:     global,
+	})
+}
+
+// Messages returns all messages that currently exist, in the order they were sent.
+func (f *FakeDiscord) Messages() []FakeMessage {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]FakeMessage(nil), f.messages...)
+}
+
+// ScheduledEvents returns all Guild Scheduled Events that currently exist, in the order they were created.
+func (f *FakeDiscord) ScheduledEvents() []discord.GuildScheduledEvent {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]discord.GuildScheduledEvent(nil), f.scheduledEvents...)
+}
+
+// Threads returns all threads that were started, in the order they were started.
+func (f *FakeDiscord) Threads() []FakeThread {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]FakeThread(nil), f.threads...)
+}
+
+// Followups returns all follow-up messages sent to interactions, in the order they were sent.
+func (f *FakeDiscord) Followups() []FakeMessage {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]FakeMessage(nil), f.followups...)
+}
+
+// responseDelay is the time a response to an interaction takes to reach the fake server, if it is not flushed.
+const responseDelay = 20 * time.Millisecond
+
+// InteractionEndpoint passes interactions to the endpoint, as Discord does. Discord only accepts follow-up
+// messages to an interaction after it received the complete response, i.e. once the endpoint flushed the response
+// or shortly after it returned. The response is recorded as request "POST /interactions/{interaction.id}/{interaction.token}/callback".
+func (f *FakeDiscord) InteractionEndpoint(endpoint http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, 50109, "The request body contains invalid JSON.")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		interaction := discord.Interaction{}
+		// Note: invalid interactions are passed on, since the endpoint has to reject them
+		json.Unmarshal(body, &interaction)
+
+		writer := &interactionResponseWriter{
+			ResponseWriter: w,
+			acknowledge: func() {
+				f.mutex.Lock()
+				defer f.mutex.Unlock()
+				if _, ok := f.interactions[interaction.Token]; ok || interaction.Token == "" {
+					return
+				}
+				// Note: the original message of a component interaction is the message of the component
+				original := discord.WebhookWithComponent{}
+				if interaction.Message != nil {
+					original = interaction.Message.WebhookWithComponent
+				}
+				f.interactions[interaction.Token] = original
+				f.requests = append(f.requests, "POST /interactions/"+interaction.ID+"/"+interaction.Token+"/callback")
+			},
+		}
+		endpoint.ServeHTTP(writer, r)
+		// Note: the delay simulates the transfer of a response, which was not flushed by the endpoint
+		time.Sleep(responseDelay)
+		writer.acknowledge()
+	})
+}
+
+// interactionResponseWriter acknowledges the interaction, once the response is flushed.
+type interactionResponseWriter struct {
+	http.ResponseWriter
+	acknowledge func()
+}
+
+func (w *interactionResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	w.acknowledge()
+}
+
+// OriginalMessage returns the original message of the interaction with the token, including all edits
+// via "@original". It returns false, if the interaction was not responded to.
+func (f *FakeDiscord) OriginalMessage(token string) (discord.WebhookWithComponent, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	message, ok := f.interactions[token]
+	return message, ok
+}
+
+// Requests returns the method and path of all requests the server received, e.g. "POST /webhooks/1/token".
+func (f *FakeDiscord) Requests() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string(nil), f.requests...)
+}
+
+func (f *FakeDiscord) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, apiPrefix)
+	f.requests = append(f.requests, r.Method+" "+path)
+
+	if len(f.rateLimits) > 0 {
+		rateLimit := f.rateLimits[0]
+		f.rateLimits = f.rateLimits[1:]
+		writeRateLimit(w, rateLimit)
+		return
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case !strings.HasPrefix(r.URL.Path, apiPrefix+"/"):
+		writeError(w, http.StatusNotFound, 0, "404: Not Found")
+	case path == "/oauth2/token":
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, 0, "405: Method Not Allowed")
+			return
+		}
+		f.handleToken(w, r)
+	case len(segments) >= 3 && segments[0] == "webhooks":
+		f.handleWebhook(w, r, segments[1], segments[2], segments[3:])
+	case len(segments) >= 2 && segments[0] == "channels":
+		if r.Header.Get("Authorization") != "Bot "+f.BotToken {
+			writeError(w, http.StatusUnauthorized, 0, "401: Unauthorized")
+			return
+		}
+		f.handleChannel(w, r, segments[1], segments[2:])
+	case len(segments) >= 3 && segments[0] == "guilds" && segments[2] == "scheduled-events":
+		if r.Header.Get("Authorization") != "Bot "+f.BotToken {
+			writeError(w, http.StatusUnauthorized, 0, "401: Unauthorized")
+			return
+		}
+		if segments[1] != GuildID {
+			writeError(w, http.StatusNotFound, 10004, "Unknown Guild")
+			return
+		}
+		f.handleScheduledEvents(w, r, segments[3:])
+	default:
+		writeError(w, http.StatusNotFound, 0, "404: Not Found")
+	}
+}
+
+// handleToken implements https://discord.com/developers/docs/topics/oauth2#authorization-code-grant
+func (f *FakeDiscord) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid form body.")
+		return
+	}
+	if r.PostForm.Get("client_id") != f.ClientID || r.PostForm.Get("client_secret") != f.ClientSecret {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "")
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		code := r.PostForm.Get("code")
+		redirectURI, ok := f.codes[code]
+		if !ok {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", `Invalid "code" in request.`)
+			return
+		}
+		if r.PostForm.Get("redirect_uri") != redirectURI {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", `Invalid "redirect_uri" in request.`)
+			return
+		}
+		// codes can only be used once
+		delete(f.codes, code)
+
+		token := f.newToken()
+		id := NewSnowflake()
+		f.webhooks[id] = "webhook-token-" + id
+		token.Webhook.ID = id
+		token.Webhook.Token = f.webhooks[id]
+		token.Webhook.ApplicationID = f.ClientID
+		token.Webhook.Name = "discord-rsvp"
+		token.Webhook.ChannelID = ChannelID
+		token.Webhook.GuildID = GuildID
+		token.Webhook.Type = 1
+		token.Webhook.URL = f.BaseURL() + "/webhooks/" + id + "/" + token.Webhook.Token
+		writeJSON(w, http.StatusOK, token)
+	case "refresh_token":
+		refreshToken := r.PostForm.Get("refresh_token")
+		if !f.refreshTokens[refreshToken] {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "")
+			return
+		}
+		// refresh tokens can only be used once
+		delete(f.refreshTokens, refreshToken)
+		writeJSON(w, http.StatusOK, f.newToken())
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+	}
+}
+
+// newToken issues a new access and refresh token. The caller must hold the lock.
+func (f *FakeDiscord) newToken() discord.WebhookTokenResponse {
+	token := discord.WebhookTokenResponse{
+		TokenType:    "Bearer",
+		AccessToken:  "access-token-" + NewSnowflake(),
+		ExpiresIn:    604800,
+		RefreshToken: "refresh-token-" + NewSnowflake(),
+	}
+	f.refreshTokens[token.RefreshToken] = true
+	return token
+}
+
+// handleWebhook implements the webhook endpoints below /webhooks/{webhook.id}/{webhook.token}.
+// The caller must hold the lock.
+func (f *FakeDiscord) handleWebhook(w http.ResponseWriter, r *http.Request, webhookID, token string, rest []string) {
+	if webhookID == ApplicationID {
+		f.handleFollowup(w, r, token, rest)
+		return
+	}
+	expectedToken, ok := f.webhooks[webhookID]
+	if !ok {
+		writeError(w, http.StatusNotFound, 10015, "Unknown Webhook")
+		return
+	}
+	if token != expectedToken {
+		writeError(w, http.StatusUnauthorized, 50027, "Invalid Webhook Token")
+		return
+	}
+
+	switch {
+	case len(rest) == 0 && r.Method == http.MethodPost:
+		message, ok := decodeMessage(w, r)
+		if !ok {
+			return
+		}
+		created := FakeMessage{
+			ID:                   NewSnowflake(),
+			ChannelID:            ChannelID,
+			WebhookID:            webhookID,
+			WebhookWithComponent: message,
+		}
+		f.messages = append(f.messages, created)
+		if r.URL.Query().Get("wait") == "true" {
+			writeJSON(w, http.StatusOK, created.response())
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case len(rest) == 2 && rest[0] == "messages":
+		index := f.messageIndex(webhookID, rest[1])
+		if index < 0 {
+			writeError(w, http.StatusNotFound, 10008, "Unknown Message")
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, f.messages[index].response())
+		case http.MethodPatch:
+			// Note: like Discord, fields that are not part of the request are kept
+			message, ok := decodeMessagePatch(w, r, f.messages[index].WebhookWithComponent)
+			if !ok {
+				return
+			}
+			f.messages[index].WebhookWithComponent = message
+			f.messages[index].EditedTimestamp = time.Now().UTC().Format(time.RFC3339Nano)
+			writeJSON(w, http.StatusOK, f.messages[index].response())
+		case http.MethodDelete:
+			f.messages = append(f.messages[:index:index], f.messages[index+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, 0, "405: Method Not Allowed")
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, 0, "405: Method Not Allowed")
+	}
+}
+
+// handleFollowup implements sending follow-up messages to and editing the original message of interactions
+// below /webhooks/{application.id}/{interaction.token}.
+// The caller must hold the lock.
+func (f *FakeDiscord) handleFollowup(w http.ResponseWriter, r *http.Request, token string, rest []string) {
+	original, ok := f.interactions[token]
+	if !ok {
+		writeError(w, http.StatusNotFound, 10062, "Unknown interaction")
+		return
+	}
+
+	switch {
+	case len(rest) == 0 && r.Method == http.MethodPost:
+		message, ok := decodeMessage(w, r)
+		if !ok {
+			return
+		}
+		created := FakeMessage{
+			ID:                   NewSnowflake(),
+			ChannelID:            ChannelID,
+			WebhookID:            ApplicationID,
+			WebhookWithComponent: message,
+		}
+		f.followups = append(f.followups, created)
+		if r.URL.Query().Get("wait") == "true" {
+			writeJSON(w, http.StatusOK, created.response())
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case len(rest) == 2 && rest[0] == "messages" && rest[1] == "@original" && r.Method == http.MethodPatch:
+		message, ok := decodeMessagePatch(w, r, original)
+		if !ok {
+			return
+		}
+		f.interactions[token] = message
+		writeJSON(w, http.StatusOK, FakeMessage{
+			ChannelID:            ChannelID,
+			WebhookWithComponent: message,
+		}.response())
+	default:
+		writeError(w, http.StatusMethodNotAllowed, 0, "405: Method Not Allowed")
+	}
+}
+
+// handleChannel implements the endpoints below /channels/{channel.id} for the channel ChannelID and its threads.
+// The caller must hold the lock.
+func (f *FakeDiscord) handleChannel(w http.ResponseWriter, r *http.Request, channelID string, rest []string) {
+	thread := -1
+	for index := range f.threads {
+		if f.threads[index].ID == channelID {
+			thread = index
+		}
+	}
+	if channelID != ChannelID && thread < 0 {
+		writeError(w, http.StatusNotFound, 10003, "Unknown Channel")
+		return
+	}
+
+	switch {
+	case len(rest) == 0 && r.Method == http.MethodPatch && thread >= 0:
+		// Note: only archiving and locking is supported for threads
+		patch := struct {
+			Archived *bool `json:"archived"`
+			Locked   *bool `json:"locked"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			writeError(w, http.StatusBadRequest, 50109, "The request body contains invalid JSON.")
+			return
+		}
+		if patch.Archived != nil {
+			f.threads[thread].Archived = *patch.Archived
+		}
+		if patch.Locked != nil {
+			f.threads[thread].Locked = *patch.Locked
+		}
+		writeJSON(w, http.StatusOK, f.threads[thread].Channel)
+	case len(rest) == 3 && rest[0] == "messages" && rest[2] == "threads" && channelID == ChannelID:
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, 0, "405: Method Not Allowed")
+			return
+		}
+		f.startThread(w, r, rest[1])
+	case len(rest) >= 1 && rest[0] == "messages":
+		if thread >= 0 && f.threads[thread].Archived && r.Method != http.MethodGet {
+			writeError(w, http.StatusBadRequest, 50083, "Thread is archived")
+			return
+		}
+		f.handleChannelMessages(w, r, channelID, rest[1:])
+	default:
+		writeError(w, http.StatusNotFound, 0, "404: Not Found")
+	}
+}
+
+// startThread implements https://discord.com/developers/docs/resources/channel#start-thread-from-message
+// The caller must hold the lock.
+func (f *FakeDiscord) startThread(w http.ResponseWriter, r *http.Request, messageID string) {
+	if f.channelMessageIndex(ChannelID, messageID) < 0 {
+		writeError(w, http.StatusNotFound, 10008, "Unknown Message")
+		return
+	}
+	for _, thread := range f.threads {
+		if thread.MessageID == messageID {
+			writeError(w, http.StatusBadRequest, 160004, "A thread has already been created for this message")
+			return
+		}
+	}
+	params := struct {
+		Name                string `json:"name"`
+		AutoArchiveDuration int    `json:"auto_archive_duration"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		writeError(w, http.StatusBadRequest, 50109, "The request body contains invalid JSON.")
+		return
+	}
+	validDuration := map[int]bool{0: true, 60: true, 1440: true, 4320: true, 10080: true}
+	if params.Name == "" || len([]rune(params.Name)) > 100 || !validDuration[params.AutoArchiveDuration] {
+		writeError(w, http.StatusBadRequest, 50035, "Invalid Form Body")
+		return
+	}
+	thread := FakeThread{
+		Channel: discord.Channel{
+			ID:       messageID,
+			Type:     11, // public thread
+			GuildID:  GuildID,
+			ParentID: ChannelID,
+			Name:     params.Name,
+		},
+		MessageID: messageID,
+	}
+	f.threads = append(f.threads, thread)
+	writeJSON(w, http.StatusCreated, thread.Channel)
+}
+
+// handleChannelMessages implements the endpoints below /channels/{channel.id}/messages for messages of the bot user.
+// The caller must hold the lock.
+func (f *FakeDiscord) handleChannelMessages(w http.ResponseWriter, r *http.Request, channelID string, rest []string) {
+	if len(rest) == 0 {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, 0, "405: Method Not Allowed")
+			return
+		}
+		message, ok := decodeMessage(w, r)
+		if !ok {
+			return
+		}
+		created := FakeMessage{
+			ID:                   NewSnowflake(),
+			ChannelID:            channelID,
+			WebhookWithComponent: message,
+		}
+		f.messages = append(f.messages, created)
+		writeJSON(w, http.StatusOK, created.response())
+		return
+	}
+
+	index := -1
+	if len(rest) == 1 {
+		// Note: the bot can only edit and delete its own messages here
+		index = f.channelMessageIndex(channelID, rest[0])
+	}
+	if index < 0 {
+		writeError(w, http.StatusNotFound, 10008, "Unknown Message")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, f.messages[index].response())
+	case http.MethodPatch:
+		message, ok := decodeMessagePatch(w, r, f.messages[index].WebhookWithComponent)
+		if !ok {
+			return
+		}
+		f.messages[index].WebhookWithComponent = message
+		f.messages[index].EditedTimestamp = time.Now().UTC().Format(time.RFC3339Nano)
+		writeJSON(w, http.StatusOK, f.messages[index].response())
+	case http.MethodDelete:
+		f.messages = append(f.messages[:index:index], f.messages[index+1:]...)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, 0, "405: Method Not Allowed")
+	}
+}
+
+// handleScheduledEvents implements the endpoints below /guilds/{guild.id}/scheduled-events for external events.
+// The caller must hold the lock.
+func (f *FakeDiscord) handleScheduledEvents(w http.ResponseWriter, r *http.Request, rest []string) {
+	if len(rest) == 0 {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, 0, "405: Method Not Allowed")
+			return
+		}
+		event := discord.GuildScheduledEvent{}
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			writeError(w, http.StatusBadRequest, 50109, "The request body contains invalid JSON.")
+			return
+		}
+		if !validScheduledEvent(event) || !event.ScheduledStartTime.After(time.Now()) {
+			writeError(w, http.StatusBadRequest, 50035, "Invalid Form Body")
+			return
+		}
+		event.ID = NewSnowflake()
+		event.GuildID = GuildID
+		event.Status = discord.ScheduledEventStatusScheduled
+		f.scheduledEvents = append(f.scheduledEvents, event)
+		writeJSON(w, http.StatusOK, event)
+		return
+	}
+
+	index := -1
+	for i, event := range f.scheduledEvents {
+		if len(rest) == 1 && event.ID == rest[0] {
+			index = i
+		}
+	}
+	if index < 0 {
+		writeError(w, http.StatusNotFound, 10070, "Unknown Guild Scheduled Event")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, f.scheduledEvents[index])
+	case http.MethodPatch:
+		current := f.scheduledEvents[index]
+		// Note: like Discord, fields that are not part of the request are kept
+		event := current
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			writeError(w, http.StatusBadRequest, 50109, "The request body contains invalid JSON.")
+			return
+		}
+		if !validScheduledEvent(event) || !validStatusTransition(current.Status, event.Status) {
+			writeError(w, http.StatusBadRequest, 50035, "Invalid Form Body")
+			return
+		}
+		event.ID = current.ID
+		event.GuildID = current.GuildID
+		f.scheduledEvents[index] = event
+		writeJSON(w, http.StatusOK, event)
+	case http.MethodDelete:
+		f.scheduledEvents = append(f.scheduledEvents[:index:index], f.scheduledEvents[index+1:]...)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, 0, "405: Method Not Allowed")
+	}
+}
+
+// validScheduledEvent checks the fields required for external events.
+func validScheduledEvent(event discord.GuildScheduledEvent) bool {
+	return event.Name != "" && len(event.Name) <= 100 &&
+		len(event.Description) <= 1000 &&
+		event.EntityType == discord.ScheduledEventEntityTypeExternal &&
+		event.EntityMetadata.Location != "" && len(event.EntityMetadata.Location) <= 100 &&
+		event.ScheduledEndTime.After(event.ScheduledStartTime)
+}
+
+// validStatusTransition reports if the status of a scheduled event can be changed, see
+// https://discord.com/developers/docs/resources/guild-scheduled-event#guild-scheduled-event-object-guild-scheduled-event-status
+func validStatusTransition(from, to discord.ScheduledEventStatus) bool {
+	switch {
+	case from == to:
+		return true
+	case from == discord.ScheduledEventStatusScheduled:
+		return to == discord.ScheduledEventStatusActive || to == discord.ScheduledEventStatusCanceled
+	case from == discord.ScheduledEventStatusActive:
+		return to == discord.ScheduledEventStatusCompleted
+	default:
+		return false
+	}
+}
+
+// messageIndex returns the index of the message sent by the webhook or -1. The caller must hold the lock.
+func (f *FakeDiscord) messageIndex(webhookID, messageID string) int {
+	for index, message := range f.messages {
+		if message.ID == messageID && message.WebhookID == webhookID {
+			return index
+		}
+	}
+	return -1
+}
+
+// channelMessageIndex returns the index of the message posted by the bot user to the channel or -1.
+// The caller must hold the lock.
+func (f *FakeDiscord) channelMessageIndex(channelID, messageID string) int {
+	index := f.messageIndex("", messageID)
+	if index >= 0 && f.messages[index].ChannelID != channelID {
+		return -1
+	}
+	return index
+}
+
+// response returns the message object as returned by Discord.
+func (m FakeMessage) response() discord.Message {
+	return discord.Message{
+		ID:                   m.ID,
+		ChannelID:            m.ChannelID,
+		WebhookID:            m.WebhookID,
+		EditedTimestamp:      m.EditedTimestamp,
+		WebhookWithComponent: m.WebhookWithComponent,
+	}
+}
+
+// decodeMessage decodes and validates the body of a request that creates a message.
+func decodeMessage(w http.ResponseWriter, r *http.Request) (discord.WebhookWithComponent, bool) {
+	message := discord.WebhookWithComponent{}
+	err := json.NewDecoder(r.Body).Decode(&message)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, 50109, "The request body contains invalid JSON.")
+		return discord.WebhookWithComponent{}, false
+	}
+	return validateMessage(w, message)
+}
+
+// decodeMessagePatch applies the fields of the body of a request that edits the current message.
+func decodeMessagePatch(w http.ResponseWriter, r *http.Request, current discord.WebhookWithComponent) (discord.WebhookWithComponent, bool) {
+	patch := map[string]json.RawMessage{}
+	err := json.NewDecoder(r.Body).Decode(&patch)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, 50109, "The request body contains invalid JSON.")
+		return discord.WebhookWithComponent{}, false
+	}
+	fields := map[string]json.RawMessage{}
+	// Note: marshalling and unmarshalling of a decoded message can not fail
+	data, _ := json.Marshal(current)
+	json.Unmarshal(data, &fields)
+	for key, value := range patch {
+		fields[key] = value
+	}
+	data, _ = json.Marshal(fields)
+	message := discord.WebhookWithComponent{}
+	if err := json.Unmarshal(data, &message); err != nil {
+		writeError(w, http.StatusBadRequest, 50035, "Invalid Form Body")
+		return discord.WebhookWithComponent{}, false
+	}
+	return validateMessage(w, message)
+}
+
+// validateMessage rejects messages that Discord would not accept.
+func validateMessage(w http.ResponseWriter, message discord.WebhookWithComponent) (discord.WebhookWithComponent, bool) {
+	if message.Content == "" && len(message.Embeds) == 0 && len(message.Components) == 0 {
+		writeError(w, http.StatusBadRequest, 50006, "Cannot send an empty message")
+		return discord.WebhookWithComponent{}, false
+	}
+	if len(message.Components) > 5 {
+		writeError(w, http.StatusBadRequest, 50035, "Invalid Form Body")
+		return discord.WebhookWithComponent{}, false
+	}
+	return message, true
+}
+
+// writeRateLimit answers with status 429, see https://discord.com/developers/docs/topics/rate-limits#exceeding-a-rate-limit
+func writeRateLimit(w http.ResponseWriter, rateLimit fakeRateLimit) {
+	seconds := rateLimit.retryAfter.Seconds()
+	w.Header().Set("Retry-After", strconv.Itoa(int(rateLimit.retryAfter.Round(time.Second)/time.Second)))
+	w.Header().Set("X-RateLimit-Remaining", "0")
+	w.Header().Set("X-RateLimit-Reset-After", strconv.FormatFloat(seconds, 'f', 3, 64))
+	if rateLimit.global {
+		w.Header().Set("X-RateLimit-Global", "true")
+	}
+	writeJSON(w, http.StatusTooManyRequests, map[string]interface{}{
+		"message":     "You are being rate limited.",
+		"retry_after": seconds,
+		"global":      rateLimit.global,
+	})
+}
+
+func writeError(w http.ResponseWriter, statusCode, code int, message string) {
+	writeJSON(w, statusCode, map[string]interface{}{
+		"code":    code,
+		"message": message,
+	})
+}
+
+func writeOAuthError(w http.ResponseWriter, statusCode int, oauthError, description string) {
+	body := map[string]string{
+		"error": oauthError,
+	}
+	if description != "" {
+		body["error_description"] = description
+	}
+	writeJSON(w, statusCode, body)
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(data)
+	if err != nil {
+		fmt.Printf("fake Discord could not write response: %v\n", err)
+	}
+}
@@ -1,17 +1,24 @@
 package main
 
 import (
-	"fmt"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"log"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/localthomas/discord-rsvp/api"
 	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/i18n"
 )
 
-func handleEventScheduling(session *discordgo.Session, state *State, config Config) {
+func handleEventScheduling(clients discordClients, state *State, config Config) {
+	// Note: the events are copied once, since HTTP handlers modify the state concurrently
+	events := state.Events()
+
 	// eventsToCreate holds all possible events before checking if
 	// they were already added to discord
 	eventsToCreate := make(map[string][]time.Time)
@@ -23,7 +30,7 @@ func handleEventScheduling(session *discordgo.Session, state *State, config Conf
 	for eventTitle, eventTimes := range eventsToCreate {
 		for index, eventTime := range eventTimes {
 			wasAlreadyCreated := false
-			for _, alreadyCreated := range state.Events {
+			for _, alreadyCreated := range events {
 				if alreadyCreated.Title == eventTitle && alreadyCreated.StartsAt.Equal(eventTime) {
 					wasAlreadyCreated = true
 					break
@@ -44,64 +51,268 @@ func handleEventScheduling(session *discordgo.Session, state *State, config Conf
 	}
 
 	// add events
+	webhookID, webhookToken, authorized := state.Webhook()
 	for eventTitle, eventTimes := range eventsToCreate {
+		channelID := config.EventChannelID(eventTitle)
+		if channelID == "" && !authorized {
+			// the webhook was not authorized yet
+			continue
+		}
 		for _, eventStartTime := range eventTimes {
-			err := addEvent(session, state, config, eventTitle, eventStartTime)
-			if err != nil {
-				fmt.Printf("could not add event %v: %v\n", eventTitle, err)
+			event := RsvpEvent{
+				Title:    eventTitle,
+				StartsAt: eventStartTime,
+			}
+			if channelID != "" {
+				event.ChannelID = channelID
+			} else {
+				event.WebhookID = webhookID
+				event.WebhookToken = webhookToken
+			}
+			// Note: adding is ignored, if the event is already waiting to be sent
+			state.AddOperation(Operation{
+				Kind:  OperationSendEvent,
+				Event: event,
+			})
+		}
+	}
+
+	// re-render the messages of events whose configuration changed since they were sent
+	for _, event := range events {
+		if time.Until(event.StartsAt) < 0 {
+			// Note: messages of events that already started are left as they are
+			continue
+		}
+		hash := renderHash(createEventMessage(event, config))
+		if hash == event.RenderHash {
+			continue
+		}
+		state.AddOperation(Operation{
+			Kind:  OperationRerenderEvent,
+			Event: event,
+		})
+		state.SetRenderHash(event.Title, event.StartsAt, hash)
+	}
+
+	// close the RSVP of events whose deadline was reached
+	for _, event := range events {
+		closesAt, ok := config.Events[event.Title].RsvpClosesAt(event.StartsAt)
+		if ok && !event.RsvpClosed && !time.Now().Before(closesAt) {
+			state.AddOperation(Operation{
+				Kind:  OperationCloseRsvp,
+				Event: event,
+			})
+			state.SetRsvpClosed(event.Title, event.StartsAt)
+		}
+	}
+
+	// confirm the games of events whose confirmation time was reached
+	for _, event := range events {
+		confirmAt, ok := config.Events[event.Title].ConfirmGamesAt(event.StartsAt)
+		if ok && !event.ConfirmationScheduled && !event.Cancelled && !time.Now().Before(confirmAt) && time.Now().Before(event.StartsAt) {
+			state.AddOperation(Operation{
+				Kind:  OperationConfirmGames,
+				Event: event,
+			})
+			state.SetConfirmationScheduled(event.Title, event.StartsAt)
+		}
+	}
+
+	// remind the attendees of upcoming events
+	for _, event := range events {
+		if event.Cancelled || !time.Now().Before(event.StartsAt) {
+			continue
+		}
+		scheduleReminder(state, config, event)
+	}
+
+	// post the final lineup of events that started
+	if config.FinalLineup {
+		for _, event := range events {
+			if !event.LineupScheduled && !event.Cancelled && !time.Now().Before(event.StartsAt) {
+				state.AddOperation(Operation{
+					Kind:  OperationPostLineup,
+					Event: event,
+				})
+				state.SetLineupScheduled(event.Title, event.StartsAt)
 			}
 		}
 	}
 
+	// mirror the events as Guild Scheduled Events
+	if config.ScheduledEventsEnabled() {
+		scheduleGuildEvents(state, config, events)
+	}
+
 	// delete events that are in the past
 	graceDuration := -2 * time.Hour
-	for _, event := range state.Events {
+	for _, event := range events {
 		durationUntil := time.Until(event.StartsAt)
 		if durationUntil < graceDuration {
 			// event is in the past, delete it
-			err := discord.DeleteWebhookMessage(session, event.WebhookID, event.WebhookToken, event.MessageID)
-			if err != nil {
-				fmt.Printf("could not delete message of event %v: %v\n", event.Title, err)
+			state.AddOperation(Operation{
+				Kind:  OperationDeleteEvent,
+				Event: event,
+			})
+			if event.ScheduledEventID != "" && config.ScheduledEventsEnabled() {
+				state.AddOperation(Operation{
+					Kind:  OperationDeleteScheduledEvent,
+					Event: event,
+				})
+			}
+			// Note: the thread is kept for reference, since deleting the message does not delete it
+			if event.ThreadID != "" {
+				state.AddOperation(Operation{
+					Kind:  OperationArchiveThread,
+					Event: event,
+				})
 			}
 			// propegate the change to the state
 			state.RemoveRsvpEvent(event.Title, event.StartsAt)
 		}
 	}
+
+	processOperations(clients, state, config)
 }
 
-func addEvent(
-	session *discordgo.Session,
-	state *State,
-	config Config,
-	eventTitle string,
-	startTime time.Time,
-) error {
-	// one "title message" that contains info about the event itself
-	webhookID := state.WebhookID
-	webhookToken := state.WebhookToken
-	message := createEventMessage(eventTitle, startTime, config.Games)
-	messageReturn, err := discord.SendWebhookWithComponents(
-		session,
-		webhookID,
-		webhookToken,
-		true,
-		message,
+// scheduleGuildEvents adds operations for creating or updating the Guild Scheduled Events of upcoming events,
+// whose configuration changed since they were last synchronised.
+func scheduleGuildEvents(state *State, config Config, events []RsvpEvent) {
+	for _, event := range events {
+		// Note: Discord does not allow to schedule events in the past
+		if event.Cancelled || time.Until(event.StartsAt) < 0 {
+			continue
+		}
+		eventConfig, ok := config.Events[event.Title]
+		if !ok || !eventConfig.OccursAt(event.StartsAt) {
+			// the event was removed from the configuration or moved to another time
+			if event.ScheduledEventID != "" {
+				state.AddOperation(Operation{
+					Kind:  OperationCancelScheduledEvent,
+					Event: event,
+				})
+				// Note: the operation keeps the ID, the event itself is not mirrored anymore
+				state.SetScheduledEventID(event.Title, event.StartsAt, "")
+			}
+			continue
+		}
+		hash := hashJSON(scheduledEventParams(event, config))
+		if hash == event.ScheduledEventHash {
+			continue
+		}
+		state.AddOperation(Operation{
+			Kind:  OperationSyncScheduledEvent,
+			Event: event,
+		})
+		state.SetScheduledEventHash(event.Title, event.StartsAt, hash)
+	}
+}
+
+// scheduledEventParams returns the Guild Scheduled Event for the event as described by the configuration.
+func scheduledEventParams(event RsvpEvent, config Config) discord.GuildScheduledEvent {
+	eventConfig := config.Events[event.Title]
+	gameTitles := []string{}
+	for _, game := range gamesToList(config.Games) {
+		gameTitles = append(gameTitles, game.Title)
+	}
+	return discord.NewExternalScheduledEvent(
+		event.Title,
+		truncate(i18n.Sprintf(config.EventLocale(event.Title), "Games: %v", strings.Join(gameTitles, ", ")), maxScheduledEventDescriptionLength),
+		eventConfig.EventLocation(),
+		event.StartsAt,
+		eventConfig.EndsAt(event.StartsAt),
 	)
+}
+
+const maxScheduledEventDescriptionLength = 1000
+
+// syncScheduledEvent creates the Guild Scheduled Event for the event or updates it, if it already exists.
+func syncScheduledEvent(client *discord.Client, state *State, config Config, event RsvpEvent) error {
+	// Note: the event of the operation might be outdated, e.g. if the scheduled event was created in the meantime
+	current, ok := state.RsvpEvent(event.Title, event.StartsAt)
+	if !ok || current.Cancelled {
+		return nil
+	}
+	params := scheduledEventParams(current, config)
+	if current.ScheduledEventID != "" {
+		return discord.ModifyGuildScheduledEvent(client, config.GuildID, current.ScheduledEventID, params)
+	}
+	created, err := discord.CreateGuildScheduledEvent(client, config.GuildID, params)
 	if err != nil {
-		return fmt.Errorf("could not send webhook message: %w", err)
+		return err
 	}
-	messageID := messageReturn.ID
+	state.SetScheduledEventID(current.Title, current.StartsAt, created.ID)
+	return nil
+}
 
-	state.AddRsvpEvent(RsvpEvent{
-		MessageID:    messageID,
-		Title:        eventTitle,
-		StartsAt:     startTime,
-		WebhookID:    webhookID,
-		WebhookToken: webhookToken,
-	})
+// cancelScheduledEvent returns a middleware that marks the event as cancelled and cancels its Guild Scheduled Event,
+// after the event message was cancelled by an organiser.
+func cancelScheduledEvent(state *State) api.Middleware {
+	return func(next api.InteractionHandler) api.InteractionHandler {
+		return func(interaction discord.Interaction, argument string) api.InteractionResponse {
+			response := next(interaction, argument)
+			if response.Update == nil {
+				return response
+			}
+			event, ok := state.EventByMessageID(interaction.Message.ID)
+			if !ok || event.Cancelled {
+				return response
+			}
+			state.SetCancelled(event.Title, event.StartsAt)
+			if event.ScheduledEventID != "" {
+				state.AddOperation(Operation{
+					Kind:  OperationCancelScheduledEvent,
+					Event: event,
+				})
+			}
+			return response
+		}
+	}
+}
+
+// sendEvent sends the message for the event and adds it to the state.
+func sendEvent(clients discordClients, state *State, config Config, event RsvpEvent) error {
+	// one "title message" that contains info about the event itself
+	message := createEventMessage(event, config)
+	messageReturn, err := postEventMessage(clients, event, message)
+	if err != nil {
+		return err
+	}
+
+	event.MessageID = messageReturn.ID
+	event.RenderHash = renderHash(message)
+	state.AddRsvpEvent(event)
+	if config.EventThreads && event.ChannelID != "" {
+		state.AddOperation(Operation{
+			Kind:  OperationCreateThread,
+			Event: event,
+		})
+	}
 	return nil
 }
 
+// renderHash returns a hash of a rendered event message, which changes when the configuration of the event changes.
+func renderHash(message discord.WebhookWithComponent) string {
+	return hashJSON(message)
+}
+
+// hashJSON returns a hash of the JSON encoding of the data.
+func hashJSON(data interface{}) string {
+	// Note: marshalling can not fail for the messages and scheduled events created by this package
+	encoded, _ := json.Marshal(data)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// truncate shortens the text to at most maxLength characters.
+func truncate(text string, maxLength int) string {
+	runes := []rune(text)
+	if len(runes) <= maxLength {
+		return text
+	}
+	return string(runes[:maxLength-1]) + "…"
+}
+
 func getPossibleTimes(eventData Event) []time.Time {
 	// check for events in the near future (look ahead duration)
 	lookAheadDuration := 5 * 24 * time.Hour
@@ -137,28 +348,61 @@ loop:
 	return times
 }
 
-func createEventMessage(eventTitle string, startTime time.Time, games map[string]string) discord.WebhookWithComponent {
+// customIDCodec returns the codec for the custom_ids of the event messages. Game titles can be too long for custom_ids
+// and must be resolvable for messages created before a restart, so the codec knows the titles of all games.
+func customIDCodec(config Config) api.CustomIDCodec {
+	titles := make([]string, 0, len(config.Games))
+	for title := range config.Games {
+		titles = append(titles, title)
+	}
+	return api.NewCustomIDCodec(titles...)
+}
+
+func createEventMessage(event RsvpEvent, config Config) discord.WebhookWithComponent {
+	locale := config.EventLocale(event.Title)
+	templates := config.eventTemplates()
 	// create a list of game names and descriptions and sort them
-	gamesList := gamesToList(games)
+	gamesList := gamesToList(config.Games)
+
+	// link the Guild Scheduled Event via the title of the embed
+	eventURL := ""
+	if event.ScheduledEventID != "" && config.ScheduledEventsEnabled() {
+		eventURL = discord.ScheduledEventURL(config.GuildID, event.ScheduledEventID)
+	}
+
+	data := eventTemplateData{
+		Title:             event.Title,
+		StartsAt:          event.StartsAt,
+		Start:             formatStartTime(event, config),
+		Locale:            locale,
+		Games:             gamesList,
+		ScheduledEventURL: eventURL,
+	}
 
 	// prepare buttons for each game
+	customIDs := customIDCodec(config)
 	// Note: maximum amount of buttons in one actionRow is 5
 	buttons := []discord.Component{}
 	counter := 0
 	tmpButtons := []discord.Component{}
 	for _, game := range gamesList {
+		style, ok := buttonStyles[game.ButtonStyle]
+		if !ok {
+			style = templates.gameButtonStyle // success by default
+		}
 		tmpButtons = append(tmpButtons, discord.Component{
-			Type:     2,
-			Label:    game.Title,
-			Style:    3, // Green / Success Button
-			CustomID: api.CustomIDButtonAddUserToGame + " " + game.Title,
+			Type:     discord.ComponentTypeButton,
+			Label:    render(templates.gameButtonLabel, newGameTemplateData(data, game, event), maxButtonLabelLength, game.Title),
+			Emoji:    game.ButtonEmoji(),
+			Style:    style,
+			CustomID: customIDs.Encode(api.CustomIDButtonAddUserToGame, game.Title),
 		})
 		if counter < 4 {
 			counter++
 		} else {
 			counter = 0
 			buttons = append(buttons, discord.Component{
-				Type:       1,
+				Type:       discord.ComponentTypeActionRow,
 				Components: tmpButtons,
 			})
 			tmpButtons = []discord.Component{}
@@ -167,19 +411,38 @@ func createEventMessage(eventTitle string, startTime time.Time, games map[string
 	// if tmpButtons still contains elements, add them to the end
 	if len(tmpButtons) > 0 {
 		buttons = append(buttons, discord.Component{
-			Type:       1,
+			Type:       discord.ComponentTypeActionRow,
 			Components: tmpButtons,
 		})
 	}
-	// add remove button last
+	// add remove, note and the organiser buttons last
 	buttons = append(buttons, discord.Component{
-		Type: 1,
+		Type: discord.ComponentTypeActionRow,
 		Components: []discord.Component{
 			{
-				Type:     2,
-				Label:    "Remove Me",
-				Style:    4, // Red / Danger Button
-				CustomID: api.CustomIDButtonRemoveUserFromEvent,
+				Type:     discord.ComponentTypeButton,
+				Label:    render(templates.removeButtonLabel, data, maxButtonLabelLength, i18n.Translate(locale, "Remove Me")),
+				Style:    discord.ButtonStyleDanger,
+				CustomID: api.EncodeCustomID(api.CustomIDButtonRemoveUserFromEvent),
+			},
+			{
+				Type:     discord.ComponentTypeButton,
+				Label:    render(templates.noteButtonLabel, data, maxButtonLabelLength, i18n.Translate(locale, "Add Note")),
+				Style:    discord.ButtonStyleSecondary,
+				CustomID: api.EncodeCustomID(api.CustomIDButtonAddNote),
+			},
+			// Note: the buttons for organisers share the row, since a message can only have 5 action rows
+			{
+				Type:     discord.ComponentTypeButton,
+				Label:    render(templates.lockButtonLabel, organiserTemplateData{Locale: locale}, maxButtonLabelLength, i18n.Translate(locale, "Lock RSVP")),
+				Style:    templates.lockButtonStyle, // secondary by default
+				CustomID: api.EncodeCustomID(api.CustomIDButtonLockEvent),
+			},
+			{
+				Type:     discord.ComponentTypeButton,
+				Label:    render(templates.cancelButtonLabel, organiserTemplateData{Locale: locale}, maxButtonLabelLength, i18n.Translate(locale, "Cancel Event")),
+				Style:    templates.cancelButtonStyle, // danger by default
+				CustomID: api.EncodeCustomID(api.CustomIDButtonCancelEvent),
 			},
 		},
 	})
@@ -187,21 +450,44 @@ func createEventMessage(eventTitle string, startTime time.Time, games map[string
 	// prepare info fields for each game
 	fields := []*discordgo.MessageEmbedField{}
 	for _, game := range gamesList {
+		gameData := newGameTemplateData(data, game, event)
 		fields = append(fields, &discordgo.MessageEmbedField{
-			Name:   game.Title,
-			Value:  game.Description,
+			Name:   render(templates.gameFieldName, gameData, maxFieldNameLength, game.Title),
+			Value:  render(templates.gameFieldValue, gameData, maxFieldValueLength, game.Description),
 			Inline: true,
 		})
 	}
 
+	// Note: an embed has only one thumbnail, so the image of the first game is shown,
+	// while the field of every game links its image
+	var thumbnail *discordgo.MessageEmbedThumbnail
+	for _, game := range gamesList {
+		if game.Image != "" {
+			thumbnail = &discordgo.MessageEmbedThumbnail{
+				URL: game.Image,
+			}
+			break
+		}
+	}
+
+	var footer *discordgo.MessageEmbedFooter
+	if text := render(templates.footer, data, maxEmbedFooterLength, ""); strings.TrimSpace(text) != "" {
+		footer = &discordgo.MessageEmbedFooter{
+			Text: text,
+		}
+	}
+
 	return discord.WebhookWithComponent{
 		WebhookParams: discordgo.WebhookParams{
 			Embeds: []*discordgo.MessageEmbed{
 				{
-					Title:       eventTitle,
-					Description: fmt.Sprintf("Event starts at %v.\nSelect the games you want to play via the buttons below.", startTime.Format(time.RFC1123)),
-					Color:       0x01579b,
+					URL:         eventURL,
+					Title:       render(templates.title, data, maxEmbedTitleLength, event.Title),
+					Description: render(templates.description, data, maxEmbedDescriptionLength, ""),
+					Color:       templates.color,
 					Fields:      fields,
+					Footer:      footer,
+					Thumbnail:   thumbnail,
 				},
 			},
 		},
@@ -209,9 +495,21 @@ func createEventMessage(eventTitle string, startTime time.Time, games map[string
 	}
 }
 
+// formatStartTime returns the start of the event as timestamps, which are shown in the local time of each member,
+// e.g. "at <t:1624219200:F> (<t:1624219200:R>)".
+func formatStartTime(event RsvpEvent, config Config) string {
+	style := config.StartTimeStyle()
+	relative := discord.FormatTimestamp(event.StartsAt, discord.TimestampStyleRelative)
+	if style == discord.TimestampStyleRelative {
+		return relative
+	}
+	return i18n.Sprintf(config.EventLocale(event.Title), "at %v (%v)", discord.FormatTimestamp(event.StartsAt, style), relative)
+}
+
+// gameEntry is a game of the configuration together with its title.
 type gameEntry struct {
-	Title       string
-	Description string
+	Title string
+	Game
 }
 
 // gameEntrySorter joins a By function and a slice of gameEntries to be sorted.
@@ -235,19 +533,22 @@ func (g *gameEntrySorter) Less(i, j int) bool {
 	return g.by(&g.games[i], &g.games[j])
 }
 
-func gamesToList(games map[string]string) []gameEntry {
+func gamesToList(games map[string]Game) []gameEntry {
 	list := []gameEntry{}
-	for title, description := range games {
+	for title, game := range games {
 		list = append(list, gameEntry{
-			Title:       title,
-			Description: description,
+			Title: title,
+			Game:  game,
 		})
 	}
 
-	// sort by title
+	// sort by order and then by title
 	sorter := &gameEntrySorter{
 		games: list,
 		by: func(p1, p2 *gameEntry) bool {
+			if p1.Order != p2.Order {
+				return p1.Order < p2.Order
+			}
 			return p1.Title < p2.Title
 		},
 	}
@@ -0,0 +1,757 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/localthomas/discord-rsvp/api"
+	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/discordtest"
+)
+
+// newTestSetup creates a state with a webhook of the fake server, which is saved to a temporary directory.
+func newTestSetup(t *testing.T) (*discordtest.FakeDiscord, discordClients, *State) {
+	fake := discordtest.NewFakeDiscord()
+	t.Cleanup(fake.Close)
+	userAgent := "DiscordBot (https://example.org, 1)"
+	clients := discordClients{
+		webhook:  discord.NewClient(fake.BaseURL(), userAgent),
+		bot:      discord.NewBotClient(fake.BaseURL(), userAgent, fake.BotToken),
+		messages: api.NewMessageLocks(),
+	}
+
+	state := resumeStateFrom(filepath.Join(t.TempDir(), stateFileName))
+	state.SetToken("Bearer", "access-token", time.Now().Add(24*time.Hour), "refresh-token")
+	webhookID, webhookToken := fake.AddWebhook()
+	state.SetWebhook(webhookID, webhookToken)
+	return fake, clients, state
+}
+
+func newTestConfig(startsAt time.Time) Config {
+	return Config{
+		Games: map[string]Game{
+			"Game1": {Description: "Description for Game1"},
+		},
+		Events: map[string]Event{
+			"Test-Event": {
+				FirstTime: startsAt,
+				Repeat:    "never",
+			},
+		},
+	}
+}
+
+func TestSchedulingSendsEventMessage(t *testing.T) {
+	fake, clients, state := newTestSetup(t)
+	config := newTestConfig(time.Now().Add(24 * time.Hour))
+
+	handleEventScheduling(clients, state, config)
+	// the event must not be sent again
+	handleEventScheduling(clients, state, config)
+
+	messages := fake.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected one message, got %v", len(messages))
+	}
+	if title := messages[0].Embeds[0].Title; title != "Test-Event" {
+		t.Errorf("expected the title Test-Event, got %v", title)
+	}
+	event, ok := state.EventByMessageID(messages[0].ID)
+	if !ok {
+		t.Fatalf("the event was not added to the state")
+	}
+	unix := event.StartsAt.Unix()
+	expectedStart := fmt.Sprintf("Event starts at <t:%v:F> (<t:%v:R>).", unix, unix)
+	if description := messages[0].Embeds[0].Description; !strings.HasPrefix(description, expectedStart) {
+		t.Errorf("expected the start as timestamps %q, got %q", expectedStart, description)
+	}
+	if event.RenderHash == "" {
+		t.Errorf("the render hash of the event was not stored")
+	}
+	if operations := state.Operations(); len(operations) != 0 {
+		t.Errorf("expected no pending operations, got %+v", operations)
+	}
+}
+
+func TestSchedulingSendsEventMessageWithMaximumGames(t *testing.T) {
+	fake, clients, state := newTestSetup(t)
+	config := newTestConfig(time.Now().Add(24 * time.Hour))
+	for index := len(config.Games); index < 20; index++ {
+		config.Games[fmt.Sprintf("Game%02d", index+1)] = Game{Description: "Description"}
+	}
+
+	handleEventScheduling(clients, state, config)
+
+	// Note: the fake server rejects messages with more than 5 action rows, like Discord
+	messages := fake.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected one message, got %v (operations: %+v)", len(messages), state.Operations())
+	}
+	if rows := len(messages[0].Components); rows != 5 {
+		t.Errorf("expected 5 action rows, got %v", rows)
+	}
+}
+
+func TestSchedulingRetriesAfterRateLimit(t *testing.T) {
+	fake, clients, state := newTestSetup(t)
+	config := newTestConfig(time.Now().Add(24 * time.Hour))
+
+	fake.RateLimitNext(50*time.Millisecond, false)
+	handleEventScheduling(clients, state, config)
+	if messages := fake.Messages(); len(messages) != 0 {
+		t.Fatalf("expected no message while rate limited, got %v", len(messages))
+	}
+	operations := state.Operations()
+	if len(operations) != 1 || operations[0].Attempts != 1 {
+		t.Fatalf("expected one pending operation after the first attempt, got %+v", operations)
+	}
+
+	// requests before the reset must not be sent at all
+	requests := len(fake.Requests())
+	handleEventScheduling(clients, state, config)
+	if len(fake.Requests()) != requests {
+		t.Errorf("a request was sent before the rate limit was reset")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	handleEventScheduling(clients, state, config)
+	if messages := fake.Messages(); len(messages) != 1 {
+		t.Fatalf("expected one message after the rate limit was reset, got %v", len(messages))
+	}
+	if operations := state.Operations(); len(operations) != 0 {
+		t.Errorf("expected no pending operations, got %+v", operations)
+	}
+}
+
+func TestSchedulingDeletesPastEvents(t *testing.T) {
+	fake, clients, state := newTestSetup(t)
+	startsAt := time.Now().Add(-3 * time.Hour)
+	config := newTestConfig(startsAt)
+
+	event := RsvpEvent{
+		Title:        "Test-Event",
+		StartsAt:     startsAt,
+		WebhookID:    state.WebhookID,
+		WebhookToken: state.WebhookToken,
+	}
+	err := sendEvent(clients, state, config, event)
+	if err != nil {
+		t.Fatalf("could not send event: %v", err)
+	}
+
+	handleEventScheduling(clients, state, config)
+	if messages := fake.Messages(); len(messages) != 0 {
+		t.Errorf("expected the message of the past event to be deleted, got %v messages", len(messages))
+	}
+	if len(state.Events()) != 0 {
+		t.Errorf("expected the past event to be removed from the state")
+	}
+}
+
+func TestSchedulingDropsOperationsForDeletedMessages(t *testing.T) {
+	_, clients, state := newTestSetup(t)
+	startsAt := time.Now().Add(-3 * time.Hour)
+	config := newTestConfig(startsAt)
+
+	// the message was deleted by a user in the meantime
+	state.AddRsvpEvent(RsvpEvent{
+		Title:        "Test-Event",
+		StartsAt:     startsAt,
+		WebhookID:    state.WebhookID,
+		WebhookToken: state.WebhookToken,
+		MessageID:    discordtest.NewSnowflake(),
+	})
+
+	handleEventScheduling(clients, state, config)
+	if operations := state.Operations(); len(operations) != 0 {
+		t.Errorf("expected the operation to be dropped after a permanent error, got %+v", operations)
+	}
+}
+
+func TestSchedulingDropsSendOperationsOfPastOrRemovedEvents(t *testing.T) {
+	fake, clients, state := newTestSetup(t)
+	startsAt := time.Now().Add(24 * time.Hour)
+	config := newTestConfig(startsAt)
+
+	// sending fails permanently, e.g. since the webhook was deleted
+	state.AddOperation(Operation{
+		Kind: OperationSendEvent,
+		Event: RsvpEvent{
+			Title:        "Test-Event",
+			StartsAt:     startsAt,
+			WebhookID:    state.WebhookID,
+			WebhookToken: "invalid-token",
+		},
+	})
+	state.AddOperation(Operation{
+		Kind: OperationSendEvent,
+		Event: RsvpEvent{
+			Title:        "Test-Event",
+			StartsAt:     time.Now().Add(-time.Minute),
+			WebhookID:    state.WebhookID,
+			WebhookToken: state.WebhookToken,
+		},
+	})
+	processOperations(clients, state, config)
+	if operations := state.Operations(); len(operations) != 1 || !operations[0].Event.StartsAt.Equal(startsAt) {
+		t.Fatalf("expected only the operation of the upcoming event to be kept, got %+v", operations)
+	}
+
+	// the event was removed from the configuration
+	delete(config.Events, "Test-Event")
+	processOperations(clients, state, config)
+	if operations := state.Operations(); len(operations) != 0 {
+		t.Errorf("expected the operation to be dropped, got %+v", operations)
+	}
+	if messages := fake.Messages(); len(messages) != 0 {
+		t.Errorf("expected no messages, got %v", len(messages))
+	}
+}
+
+func TestSchedulingMirrorsScheduledEvents(t *testing.T) {
+	fake, clients, state := newTestSetup(t)
+	startsAt := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	config := newTestConfig(startsAt)
+	config.BotToken = fake.BotToken
+	config.GuildID = discordtest.GuildID
+
+	// the first run sends the message, the second one creates the scheduled event for it
+	handleEventScheduling(clients, state, config)
+	handleEventScheduling(clients, state, config)
+	scheduledEvents := fake.ScheduledEvents()
+	if len(scheduledEvents) != 1 {
+		t.Fatalf("expected one scheduled event, got %v", len(scheduledEvents))
+	}
+	scheduledEvent := scheduledEvents[0]
+	if scheduledEvent.Name != "Test-Event" || !scheduledEvent.ScheduledStartTime.Equal(startsAt) ||
+		!scheduledEvent.ScheduledEndTime.Equal(startsAt.Add(defaultEventDuration)) {
+		t.Errorf("unexpected scheduled event %+v", scheduledEvent)
+	}
+
+	// the next run links the scheduled event from the embed
+	handleEventScheduling(clients, state, config)
+	messages := fake.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected one message, got %v", len(messages))
+	}
+	expectedURL := discord.ScheduledEventURL(discordtest.GuildID, scheduledEvent.ID)
+	if url := messages[0].Embeds[0].URL; url != expectedURL {
+		t.Errorf("expected the embed to link %v, got %q", expectedURL, url)
+	}
+
+	// changes of the configuration are synchronised
+	config.Games["Game2"] = Game{Description: "Description for Game2"}
+	handleEventScheduling(clients, state, config)
+	scheduledEvents = fake.ScheduledEvents()
+	if len(scheduledEvents) != 1 || scheduledEvents[0].Description != "Games: Game1, Game2" {
+		t.Errorf("expected the description of the scheduled event to be updated, got %+v", scheduledEvents)
+	}
+
+	// moving the event cancels the scheduled event of the old time
+	eventConfig := config.Events["Test-Event"]
+	eventConfig.FirstTime = startsAt.Add(time.Hour)
+	config.Events["Test-Event"] = eventConfig
+	handleEventScheduling(clients, state, config)
+	handleEventScheduling(clients, state, config)
+	scheduledEvents = fake.ScheduledEvents()
+	if len(scheduledEvents) != 2 {
+		t.Fatalf("expected a second scheduled event for the new time, got %+v", scheduledEvents)
+	}
+	if scheduledEvents[0].Status != discord.ScheduledEventStatusCanceled {
+		t.Errorf("expected the scheduled event of the old time to be cancelled, got status %v", scheduledEvents[0].Status)
+	}
+	if !scheduledEvents[1].ScheduledStartTime.Equal(eventConfig.FirstTime) {
+		t.Errorf("expected the new scheduled event to start at %v, got %v", eventConfig.FirstTime, scheduledEvents[1].ScheduledStartTime)
+	}
+}
+
+func TestCancelledEventCancelsScheduledEvent(t *testing.T) {
+	fake, clients, state := newTestSetup(t)
+	config := newTestConfig(time.Now().Add(24 * time.Hour))
+	config.BotToken = fake.BotToken
+	config.GuildID = discordtest.GuildID
+	handleEventScheduling(clients, state, config)
+	handleEventScheduling(clients, state, config)
+
+	message := fake.Messages()[0]
+	interaction := discordtest.ButtonInteraction("cancel_event", "846600000000000001", discord.Message{
+		ID:                   message.ID,
+		WebhookWithComponent: message.WebhookWithComponent,
+	})
+	response := cancelScheduledEvent(state)(eventMessages(config).HandleCancelEvent)(interaction, "")
+	if response.Update == nil {
+		t.Fatalf("expected the message to be updated, got %+v", response)
+	}
+
+	handleEventScheduling(clients, state, config)
+	if status := fake.ScheduledEvents()[0].Status; status != discord.ScheduledEventStatusCanceled {
+		t.Errorf("expected the scheduled event to be cancelled, got status %v", status)
+	}
+}
+
+func TestSchedulingInBotTokenMode(t *testing.T) {
+	fake, clients, _ := newTestSetup(t)
+	// the webhook was never authorized
+	state := resumeStateFrom(filepath.Join(t.TempDir(), stateFileName))
+	config := newTestConfig(time.Now().Add(24 * time.Hour))
+	config.BotToken = fake.BotToken
+	config.ChannelID = discordtest.ChannelID
+	if config.UsesWebhook() {
+		t.Fatalf("expected no event to use the webhook")
+	}
+
+	handleEventScheduling(clients, state, config)
+	messages := fake.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected one message, got %v", len(messages))
+	}
+	if messages[0].WebhookID != "" || messages[0].ChannelID != discordtest.ChannelID {
+		t.Errorf("expected the message to be posted by the bot to the channel, got %+v", messages[0])
+	}
+	event, ok := state.EventByMessageID(messages[0].ID)
+	if !ok || event.ChannelID != discordtest.ChannelID || event.WebhookID != "" {
+		t.Fatalf("unexpected event in state: %+v", event)
+	}
+
+	// the message is edited via the channel as well
+	state.SetRenderHash(event.Title, event.StartsAt, "outdated")
+	handleEventScheduling(clients, state, config)
+	if operations := state.Operations(); len(operations) != 0 {
+		t.Errorf("expected no pending operations, got %+v", operations)
+	}
+	for _, request := range fake.Requests() {
+		if strings.HasPrefix(request, "POST /webhooks/") || strings.HasPrefix(request, "POST /oauth2/") {
+			t.Errorf("unexpected request %v in bot-token mode", request)
+		}
+	}
+}
+
+func TestSchedulingWaitsForWebhook(t *testing.T) {
+	fake, clients, _ := newTestSetup(t)
+	state := resumeStateFrom(filepath.Join(t.TempDir(), stateFileName))
+	config := newTestConfig(time.Now().Add(24 * time.Hour))
+
+	handleEventScheduling(clients, state, config)
+	if requests := fake.Requests(); len(requests) != 0 {
+		t.Errorf("expected no requests before the webhook was authorized, got %v", requests)
+	}
+}
+
+func TestSchedulingCreatesEventThreads(t *testing.T) {
+	fake, clients, state := newTestSetup(t)
+	config := newTestConfig(time.Now().Add(24 * time.Hour))
+	config.BotToken = fake.BotToken
+	config.ChannelID = discordtest.ChannelID
+	config.EventThreads = true
+	handleEventScheduling(clients, state, config)
+	handleEventScheduling(clients, state, config)
+
+	threads := fake.Threads()
+	if len(threads) != 1 {
+		t.Fatalf("expected one thread, got %+v", threads)
+	}
+	message := fake.Messages()[0]
+	event, _ := state.EventByMessageID(message.ID)
+	if threads[0].MessageID != message.ID || event.ThreadID != threads[0].ID {
+		t.Errorf("expected the thread to be started on the event message, got %+v for event %+v", threads[0], event)
+	}
+	sunday := time.Date(2030, 6, 23, 20, 0, 0, 0, time.UTC)
+	if name := threadName(RsvpEvent{Title: "Test-Event", StartsAt: sunday}); name != "Test-Event – 2030-06-23" {
+		t.Errorf("unexpected thread name %q", name)
+	}
+
+	// signing up is posted to the thread
+	interaction := discordtest.ButtonInteraction("add_user", "846600000000000001", discord.Message{
+		ID:                   message.ID,
+		WebhookWithComponent: message.WebhookWithComponent,
+	})
+	response := postAttendeeChanges(state, config)(eventMessages(config).HandleAddUserToGame)(interaction, "Game1")
+	if response.Update == nil {
+		t.Fatalf("expected the message to be updated, got %+v", response)
+	}
+	handleEventScheduling(clients, state, config)
+	var posts []discordtest.FakeMessage
+	for _, post := range fake.Messages() {
+		if post.ChannelID == event.ThreadID {
+			posts = append(posts, post)
+		}
+	}
+	if len(posts) != 1 || posts[0].Content != "<@846600000000000001> signed up for **Game1**." {
+		t.Fatalf("expected one post about the new attendee, got %+v", posts)
+	}
+	if posts[0].AllowedMentions == nil || len(posts[0].AllowedMentions.Parse) != 0 {
+		t.Errorf("expected the post to not notify the attendee, got %+v", posts[0].AllowedMentions)
+	}
+
+	// the thread is archived when the event is removed
+	past := event
+	past.StartsAt = time.Now().Add(-3 * time.Hour)
+	state.RemoveRsvpEvent(event.Title, event.StartsAt)
+	state.AddRsvpEvent(past)
+	config.Events = map[string]Event{}
+	handleEventScheduling(clients, state, config)
+	if thread := fake.Threads()[0]; !thread.Archived || !thread.Locked {
+		t.Errorf("expected the thread to be archived and locked, got %+v", thread)
+	}
+	if operations := state.Operations(); len(operations) != 0 {
+		t.Errorf("expected no pending operations, got %+v", operations)
+	}
+}
+
+func TestSchedulingSendsRemindersOnce(t *testing.T) {
+	fake, clients, state := newTestSetup(t)
+	config := newTestConfig(time.Now().Add(time.Hour))
+	config.Events["Test-Event"] = Event{
+		FirstTime: config.Events["Test-Event"].FirstTime,
+		Repeat:    "never",
+		Reminders: []string{"24h", "2h", "15m"},
+	}
+	handleEventScheduling(clients, state, config)
+	event := state.Events()[0]
+
+	// sign up a user via the event message
+	message := fake.Messages()[0]
+	interaction := discordtest.ButtonInteraction("add_user", "846600000000000001", discord.Message{
+		ID:                   message.ID,
+		WebhookWithComponent: message.WebhookWithComponent,
+	})
+	response := eventMessages(config).HandleAddUserToGame(interaction, "Game1")
+	_, err := discord.EditWebhookMessage(clients.webhook, event.WebhookID, event.WebhookToken, event.MessageID, *response.Update)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handleEventScheduling(clients, state, config)
+	// the state is resumed, as after a restart
+	state = resumeStateFrom(state.path)
+	handleEventScheduling(clients, state, config)
+
+	messages := fake.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("expected the event message and one reminder, got %v messages", len(messages))
+	}
+	reminder := messages[1]
+	if !strings.HasPrefix(reminder.Content, "Reminder: **Test-Event** starts at") || !strings.HasSuffix(reminder.Content, "<@846600000000000001>") {
+		t.Errorf("unexpected reminder %q", reminder.Content)
+	}
+	if mentions := reminder.AllowedMentions; mentions == nil || len(mentions.Parse) != 0 || len(mentions.Users) != 1 || mentions.Users[0] != "846600000000000001" {
+		t.Errorf("expected only the attendee to be mentioned, got %+v", reminder.AllowedMentions)
+	}
+	if sent := state.Events()[0].RemindersSent; len(sent) != 2 {
+		t.Errorf("expected the due reminders 24h and 2h to be recorded, got %v", sent)
+	}
+}
+
+func TestRemindersMentionAllAttendees(t *testing.T) {
+	fake, clients, state := newTestSetup(t)
+	config := newTestConfig(time.Now().Add(time.Hour))
+	config.Games = map[string]Game{}
+	for _, game := range []string{"Game1", "Game2", "Game3", "Game4"} {
+		config.Games[game] = Game{Description: "Description for " + game}
+	}
+	config.Events["Test-Event"] = Event{
+		FirstTime: config.Events["Test-Event"].FirstTime,
+		Repeat:    "never",
+		Reminders: []string{"2h"},
+	}
+	handleEventScheduling(clients, state, config)
+	event := state.Events()[0]
+
+	// more attendees than fit into one message, split over several games due to the limit of the fields
+	userIDs := []string{}
+	for game := 1; game <= 4; game++ {
+		for index := 0; index < 40; index++ {
+			userID := fmt.Sprintf("8466000000000%02v%03v", game, index)
+			userIDs = append(userIDs, userID)
+			message := fake.Messages()[0]
+			interaction := discordtest.ButtonInteraction("add_user", userID, discord.Message{
+				ID:                   message.ID,
+				WebhookWithComponent: message.WebhookWithComponent,
+			})
+			response := eventMessages(config).HandleAddUserToGame(interaction, fmt.Sprintf("Game%v", game))
+			if response.Update == nil {
+				t.Fatalf("could not sign up %v: %+v", userID, response)
+			}
+			_, err := discord.EditWebhookMessage(clients.webhook, event.WebhookID, event.WebhookToken, event.MessageID, *response.Update)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		handleEventScheduling(clients, state, config)
+	}
+
+	reminders := fake.Messages()[1:]
+	if len(reminders) < 2 {
+		t.Fatalf("expected the reminder to be split into several messages, got %v", len(reminders))
+	}
+	mentioned := []string{}
+	for _, reminder := range reminders {
+		if !strings.HasPrefix(reminder.Content, "Reminder: **Test-Event** starts at") {
+			t.Errorf("unexpected reminder %q", reminder.Content)
+		}
+		if length := utf8.RuneCountInString(reminder.Content); length > maxMessageContentLength {
+			t.Errorf("expected at most %v characters, got %v", maxMessageContentLength, length)
+		}
+		if reminder.AllowedMentions == nil || len(reminder.AllowedMentions.Users) > maxReminderMentions {
+			t.Fatalf("expected at most %v mentioned users, got %+v", maxReminderMentions, reminder.AllowedMentions)
+		}
+		for _, userID := range reminder.AllowedMentions.Users {
+			if !strings.Contains(reminder.Content, "<@"+userID+">") {
+				t.Errorf("the allowed user %v is not mentioned in %q", userID, reminder.Content)
+			}
+		}
+		mentioned = append(mentioned, reminder.AllowedMentions.Users...)
+	}
+	if !reflect.DeepEqual(mentioned, userIDs) {
+		t.Errorf("expected every attendee to be mentioned once, got %v of %v", len(mentioned), len(userIDs))
+	}
+}
+
+func TestRemindersAreNotSentAfterStart(t *testing.T) {
+	fake, clients, state := newTestSetup(t)
+	startsAt := time.Now().Add(-time.Minute)
+	config := newTestConfig(startsAt)
+	event := RsvpEvent{
+		Title:        "Test-Event",
+		StartsAt:     startsAt,
+		WebhookID:    state.WebhookID,
+		WebhookToken: state.WebhookToken,
+		MessageID:    discordtest.NewSnowflake(),
+	}
+	state.AddRsvpEvent(event)
+
+	// e.g. a reminder that was retried until the event started
+	state.AddOperation(Operation{
+		Kind:  OperationSendReminder,
+		Event: event,
+		ID:    "15m0s",
+	})
+	processOperations(clients, state, config)
+
+	if operations := state.Operations(); len(operations) != 0 {
+		t.Errorf("expected the reminder to be dropped, got %+v", operations)
+	}
+	if requests := fake.Requests(); len(requests) != 0 {
+		t.Errorf("expected no requests, got %v", requests)
+	}
+}
+
+func TestPromotedUsersAreNotified(t *testing.T) {
+	fake, clients, state := newTestSetup(t)
+	config := newTestConfig(time.Now().Add(24 * time.Hour))
+	config.Games["Game1"] = Game{Description: "Description for Game1", MaxPlayers: 1}
+	handleEventScheduling(clients, state, config)
+
+	// the first user gets the only spot, the second one is put on the waitlist
+	message := fake.Messages()[0]
+	current := discord.Message{
+		ID:                   message.ID,
+		WebhookWithComponent: message.WebhookWithComponent,
+	}
+	handler := notifyPromotedUsers(state, config)
+	for _, userID := range []string{"846600000000000001", "846600000000000002"} {
+		response := handler(eventMessages(config).HandleAddUserToGame)(discordtest.ButtonInteraction("add_user", userID, current), "Game1")
+		current.WebhookWithComponent = *response.Update
+	}
+	handleEventScheduling(clients, state, config)
+	if messages := fake.Messages(); len(messages) != 1 {
+		t.Fatalf("expected no notification without promotion, got %v messages", len(messages))
+	}
+
+	response := handler(eventMessages(config).HandleRemoveUserFromEvent)(discordtest.ButtonInteraction("remove_user", "846600000000000001", current), "")
+	if response.Update == nil {
+		t.Fatalf("expected the message to be updated, got %+v", response)
+	}
+	handleEventScheduling(clients, state, config)
+	messages := fake.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("expected the event message and one notification, got %v messages", len(messages))
+	}
+	notification := messages[1]
+	if !strings.HasPrefix(notification.Content, "<@846600000000000002>: a spot in **Game1** opened up") {
+		t.Errorf("unexpected notification %q", notification.Content)
+	}
+	if mentions := notification.AllowedMentions; mentions == nil || len(mentions.Parse) != 1 || mentions.Parse[0] != "users" {
+		t.Errorf("expected the promoted user to be mentioned, got %+v", notification.AllowedMentions)
+	}
+}
+
+func TestSchedulingConfirmsGames(t *testing.T) {
+	fake, clients, state := newTestSetup(t)
+	config := newTestConfig(time.Now().Add(time.Hour))
+	config.Games["Game1"] = Game{Description: "Description for Game1", MinPlayers: 2}
+	config.Games["Game2"] = Game{Description: "Description for Game2"}
+	handleEventScheduling(clients, state, config)
+	event := state.Events()[0]
+
+	message := fake.Messages()[0]
+	for _, game := range []string{"Game1", "Game2"} {
+		interaction := discordtest.ButtonInteraction("add_user", "846600000000000001", discord.Message{
+			ID:                   message.ID,
+			WebhookWithComponent: message.WebhookWithComponent,
+		})
+		response := eventMessages(config).HandleAddUserToGame(interaction, game)
+		if _, err := discord.EditWebhookMessage(clients.webhook, event.WebhookID, event.WebhookToken, event.MessageID, *response.Update); err != nil {
+			t.Fatal(err)
+		}
+		message = fake.Messages()[0]
+	}
+
+	// Note: the confirmation time is already reached, so the games are confirmed right after enabling it
+	config.Events["Test-Event"] = Event{
+		FirstTime:      config.Events["Test-Event"].FirstTime,
+		Repeat:         "never",
+		ConfirmGames:   "2h",
+		CancelIfNoGame: true,
+	}
+	handleEventScheduling(clients, state, config)
+	// the changed status of the games is rendered in the next run
+	handleEventScheduling(clients, state, config)
+
+	messages := fake.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("expected the event message and a summary, got %v messages", len(messages))
+	}
+	summary := messages[1].Content
+	if !strings.Contains(summary, "❌ **Game1** is not happening with 1 of at least 2 players.") || !strings.Contains(summary, "✅ **Game2** is happening with 1 players.") {
+		t.Errorf("unexpected summary %q", summary)
+	}
+	if state.Events()[0].Cancelled {
+		t.Errorf("expected the event to take place, since Game2 is happening")
+	}
+	fields := messages[0].Embeds[0].Fields
+	if !strings.HasSuffix(fields[0].Value, "**❌ Not happening**") || !strings.HasSuffix(fields[1].Value, "**✅ Happening**") {
+		t.Errorf("expected the status of the games in the event message, got %q and %q", fields[0].Value, fields[1].Value)
+	}
+	if len(messages[0].Embeds) != 2 {
+		t.Errorf("expected the attendees to be kept, got %v embeds", len(messages[0].Embeds))
+	}
+}
+
+func TestSchedulingCancelsEventWithoutGames(t *testing.T) {
+	fake, clients, state := newTestSetup(t)
+	config := newTestConfig(time.Now().Add(time.Hour))
+	config.Events["Test-Event"] = Event{
+		FirstTime:      config.Events["Test-Event"].FirstTime,
+		Repeat:         "never",
+		ConfirmGames:   "2h",
+		MinAttendees:   2,
+		CancelIfNoGame: true,
+	}
+	handleEventScheduling(clients, state, config)
+	handleEventScheduling(clients, state, config)
+
+	messages := fake.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("expected the event message and a summary, got %v messages", len(messages))
+	}
+	if !state.Events()[0].Cancelled || !strings.HasPrefix(messages[0].Embeds[0].Title, "Cancelled: ") {
+		t.Errorf("expected the event to be cancelled, got %q", messages[0].Embeds[0].Title)
+	}
+	expected := "The event needs at least 2 attendees, but only 0 signed up.\nThe event was cancelled, since no game is happening."
+	if !strings.HasSuffix(messages[1].Content, expected) {
+		t.Errorf("unexpected summary %q", messages[1].Content)
+	}
+}
+
+func TestSchedulingPostsFinalLineup(t *testing.T) {
+	fake, clients, state := newTestSetup(t)
+	startsAt := time.Now().Add(-time.Minute)
+	config := newTestConfig(startsAt)
+	config.FinalLineup = true
+
+	event := RsvpEvent{
+		Title:        "Test-Event",
+		StartsAt:     startsAt,
+		WebhookID:    state.WebhookID,
+		WebhookToken: state.WebhookToken,
+	}
+	if err := sendEvent(clients, state, config, event); err != nil {
+		t.Fatalf("could not send event: %v", err)
+	}
+	event = state.Events()[0]
+	message := fake.Messages()[0]
+	interaction := discordtest.ButtonInteraction("add_user", "846600000000000001", discord.Message{
+		ID:                   message.ID,
+		WebhookWithComponent: message.WebhookWithComponent,
+	})
+	response := eventMessages(config).HandleAddUserToGame(interaction, "Game1")
+	if _, err := discord.EditWebhookMessage(clients.webhook, event.WebhookID, event.WebhookToken, event.MessageID, *response.Update); err != nil {
+		t.Fatal(err)
+	}
+
+	handleEventScheduling(clients, state, config)
+	// the lineup must not be posted again
+	handleEventScheduling(clients, state, config)
+
+	messages := fake.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("expected the event message and the lineup, got %v messages", len(messages))
+	}
+	for _, row := range messages[0].Components {
+		for _, component := range row.Components {
+			if !component.Disabled {
+				t.Errorf("expected all components of the event message to be disabled, got %+v", component)
+			}
+		}
+	}
+	lineup := messages[1].Embeds[0]
+	if lineup.Title != "Lineup: Test-Event" || len(lineup.Fields) != 1 {
+		t.Fatalf("unexpected lineup %+v", lineup)
+	}
+	if field := lineup.Fields[0]; field.Name != "Game1 (1)" || field.Value != "<@846600000000000001>" {
+		t.Errorf("unexpected field %+v", field)
+	}
+	if mentions := messages[1].AllowedMentions; mentions == nil || len(mentions.Parse) != 0 {
+		t.Errorf("expected the lineup to not notify anyone, got %+v", mentions)
+	}
+}
+
+func TestRerenderingWaitsForInteractionResponses(t *testing.T) {
+	fake, clients, state := newTestSetup(t)
+	config := newTestConfig(time.Now().Add(24 * time.Hour))
+	handleEventScheduling(clients, state, config)
+	event := state.Events()[0]
+
+	// an interaction response is in progress, while the configuration changes
+	message := fake.Messages()[0]
+	unlock := clients.messages.Lock(event.MessageID)
+	config.Games["Game1"] = Game{Description: "Changed description"}
+	state.AddOperation(Operation{
+		Kind:  OperationRerenderEvent,
+		Event: event,
+	})
+	done := make(chan struct{})
+	go func() {
+		processOperations(clients, state, config)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	interaction := discordtest.ButtonInteraction("add_user", "846600000000000001", discord.Message{
+		ID:                   message.ID,
+		WebhookWithComponent: message.WebhookWithComponent,
+	})
+	response := eventMessages(config).HandleAddUserToGame(interaction, "Game1")
+	if _, err := discord.EditWebhookMessage(clients.webhook, event.WebhookID, event.WebhookToken, event.MessageID, *response.Update); err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+	<-done
+
+	message = fake.Messages()[0]
+	if attendees := api.Attendees(message.WebhookWithComponent); len(attendees["Game1"]) != 1 {
+		t.Errorf("expected the sign-up to be kept, got %v", attendees)
+	}
+	if !strings.Contains(message.Embeds[0].Fields[0].Value, "Changed description") {
+		t.Errorf("expected the message to be rendered again, got %+v", message.Embeds[0].Fields[0])
+	}
+}
@@ -0,0 +1,101 @@
+// Package i18n translates the texts of the bot via message catalogs.
+// The English source text is the key of a translation, so that texts without a translation are shown in English.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// DefaultLocale is the locale of the source texts.
+const DefaultLocale = "en"
+
+//go:embed catalogs/*.json
+var catalogFiles embed.FS
+
+// catalogs maps a locale, e.g. "de", to the translations of the source texts.
+var catalogs = loadCatalogs()
+
+func loadCatalogs() map[string]map[string]string {
+	files, err := catalogFiles.ReadDir("catalogs")
+	if err != nil {
+		panic(fmt.Sprintf("could not read message catalogs: %v", err))
+	}
+	loaded := make(map[string]map[string]string)
+	for _, file := range files {
+		data, err := catalogFiles.ReadFile(path.Join("catalogs", file.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("could not read message catalog %v: %v", file.Name(), err))
+		}
+		catalog := make(map[string]string)
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			panic(fmt.Sprintf("invalid message catalog %v: %v", file.Name(), err))
+		}
+		loaded[strings.TrimSuffix(file.Name(), ".json")] = catalog
+	}
+	return loaded
+}
+
+// Locales returns the supported locales, including DefaultLocale.
+func Locales() []string {
+	locales := []string{DefaultLocale}
+	for locale := range catalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Supported reports if texts can be translated to the locale or if it is the DefaultLocale.
+// Regional locales of Discord, e.g. "en-US", are supported, if their language is.
+func Supported(locale string) bool {
+	language := languageOf(locale)
+	_, ok := catalogs[locale]
+	_, languageOK := catalogs[language]
+	return ok || languageOK || language == DefaultLocale
+}
+
+// Translate returns the translation of the source text for the locale, e.g. "de" or "en-US".
+// If there is no translation, the text is returned as is.
+func Translate(locale, text string) string {
+	if catalog, ok := catalogs[locale]; ok {
+		if translation, ok := catalog[text]; ok {
+			return translation
+		}
+	}
+	if catalog, ok := catalogs[languageOf(locale)]; ok {
+		if translation, ok := catalog[text]; ok {
+			return translation
+		}
+	}
+	return text
+}
+
+// Sprintf translates the format for the locale and formats it with the arguments.
+func Sprintf(locale, format string, args ...interface{}) string {
+	return fmt.Sprintf(Translate(locale, format), args...)
+}
+
+// Variants returns the source text and all of its translations, e.g. to recognise a text in a message,
+// that was created with another locale.
+func Variants(text string) []string {
+	variants := []string{text}
+	for _, locale := range Locales() {
+		if translation := Translate(locale, text); translation != text {
+			variants = append(variants, translation)
+		}
+	}
+	return variants
+}
+
+// languageOf returns the language of a regional locale, e.g. "pt" for "pt-BR".
+func languageOf(locale string) string {
+	if index := strings.Index(locale, "-"); index >= 0 {
+		return locale[:index]
+	}
+	return locale
+}
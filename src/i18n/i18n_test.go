@@ -0,0 +1,57 @@
+package i18n
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		locale   string
+		expected string
+	}{
+		{locale: "de", expected: "Teilnehmer"},
+		// regional locales of Discord fall back to their language
+		{locale: "de-AT", expected: "Teilnehmer"},
+		{locale: "en-US", expected: "Attendees"},
+		{locale: "", expected: "Attendees"},
+		{locale: "ja", expected: "Attendees"},
+	}
+	for _, test := range tests {
+		if translation := Translate(test.locale, "Attendees"); translation != test.expected {
+			t.Errorf("expected %q for locale %q, got %q", test.expected, test.locale, translation)
+		}
+	}
+	if text := Translate("de", "a text without translation"); text != "a text without translation" {
+		t.Errorf("expected the source text, got %q", text)
+	}
+}
+
+func TestSupported(t *testing.T) {
+	for _, locale := range []string{"en", "en-GB", "de"} {
+		if !Supported(locale) {
+			t.Errorf("expected locale %q to be supported", locale)
+		}
+	}
+	if Supported("ja") {
+		t.Errorf("expected locale ja to be unsupported")
+	}
+}
+
+// TestCatalogsKeepFormatVerbs ensures that every translation has the same formatting verbs as its source text.
+func TestCatalogsKeepFormatVerbs(t *testing.T) {
+	verbs := regexp.MustCompile(`%[a-z]`)
+	for locale, catalog := range catalogs {
+		for text, translation := range catalog {
+			expected := verbs.FindAllString(text, -1)
+			actual := verbs.FindAllString(translation, -1)
+			sort.Strings(expected)
+			sort.Strings(actual)
+			if strings.Join(expected, " ") != strings.Join(actual, " ") {
+				t.Errorf("translation of %q to %v has the verbs %v instead of %v", text, locale, actual, expected)
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/localthomas/discord-rsvp/api"
+	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/i18n"
+)
+
+// postLineup closes the RSVP of the event message and posts the attendees of each game in a separate message,
+// which is kept after the event message is deleted. Nothing is posted, if the event was cancelled.
+func postLineup(clients discordClients, state *State, config Config, event RsvpEvent) error {
+	current, ok := state.RsvpEvent(event.Title, event.StartsAt)
+	if !ok || current.Cancelled {
+		return nil
+	}
+	var message discord.WebhookWithComponent
+	err := changeEventMessage(clients, current, func(changed *discord.WebhookWithComponent) bool {
+		// Note: closing is skipped for messages that were already closed, so that a retry does not edit the message again
+		if !current.RsvpClosed {
+			api.CloseRsvpMessage(changed, config.EventLocale(current.Title))
+		}
+		message = *changed
+		return !current.RsvpClosed
+	})
+	if err != nil {
+		return err
+	}
+	if !current.RsvpClosed {
+		state.SetRsvpClosed(current.Title, current.StartsAt)
+	}
+	_, err = postEventMessage(clients, current, lineupMessage(current, config, message))
+	return err
+}
+
+// lineupMessage lists the attendees of each game of the event message in an embed, in the same format as the event message.
+// Games that are not happening according to the confirmation of the games are marked.
+func lineupMessage(event RsvpEvent, config Config, message discord.WebhookWithComponent) discord.WebhookWithComponent {
+	locale := config.EventLocale(event.Title)
+	embed := &discordgo.MessageEmbed{
+		Title:       i18n.Sprintf(locale, "Lineup: %v", event.Title),
+		Description: i18n.Sprintf(locale, "The event started %v.", formatStartTime(event, config)),
+		Color:       config.eventTemplates().attendeesColor,
+	}
+	if len(message.Embeds) > 1 {
+		for _, field := range message.Embeds[1].Fields {
+			lineupField := *field
+			if happening, ok := event.GamesHappening[api.GameOfField(field.Name)]; ok && !happening {
+				lineupField.Name = "❌ " + lineupField.Name
+			}
+			embed.Fields = append(embed.Fields, &lineupField)
+		}
+	}
+	if len(embed.Fields) == 0 {
+		embed.Description += "\n" + i18n.Translate(locale, "Nobody signed up for this event.")
+	}
+	return discord.WebhookWithComponent{
+		WebhookParams: discordgo.WebhookParams{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			// Note: an empty list of mention types suppresses all notifications
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		},
+	}
+}
@@ -3,19 +3,20 @@ package main
 import (
 	"crypto/ed25519"
 	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"time"
 
-	"github.com/bwmarrin/discordgo"
 	"github.com/localthomas/discord-rsvp/api"
 	"github.com/localthomas/discord-rsvp/discord"
 )
 
 const Version = 1 // static version of this software
 const WebhookTokenEndpoint = "/webhook-token"
+const MetricsEndpoint = "/metrics"
 const ConfigFilePath = "config/config.json"
 
 func main() {
@@ -43,67 +44,151 @@ func main() {
 		state.SetToken("", "", time.Time{}, "")
 	}
 
-	go func() {
-		var session *discordgo.Session
+	userAgent := fmt.Sprintf("DiscordBot (%v, %v)", config.ThisInstanceURL, Version)
+	client := discord.NewClient(config.APIBaseURL(), userAgent)
+	handlerRouter := api.NewInteractionRouter(client, customIDCodec(config))
+	clients := discordClients{
+		webhook:  client,
+		messages: handlerRouter.MessageLocks(),
+	}
+	if config.BotToken != "" {
+		clients.bot = discord.NewBotClient(config.APIBaseURL(), userAgent, config.BotToken)
+	}
 
+	go func() {
 		// never ending loop that executes tasks
 		for {
-			// check if the token needs to be refreshed
-			if time.Until(state.ExpiresAt) < 1*time.Hour && state.RefreshToken != "" {
-				token, err := discord.RefreshToken(
-					config.ClientID,
-					config.ClientSecret,
-					state.RefreshToken)
-				if err != nil {
-					fmt.Printf("could not refresh access token: %v\n", err)
-				} else {
-					state.SetToken(
-						token.TokenType,
-						token.AccessToken,
-						time.Now().Add(time.Duration(token.ExpiresIn)*time.Second),
-						token.RefreshToken)
-					fmt.Println("token was refreshed")
-				}
+			if config.UsesWebhook() {
+				handleTokenRefresh(client, state, config)
 			}
 
-			if session == nil && state.AuthorizationToken != "" {
-				newSession, err := discordgo.New(state.AuthorizationTokenType + " " + state.AuthorizationToken)
-				if err != nil {
-					log.Fatalf("could not create session: %v\n", err)
-				} else {
-					session = newSession
-					session.UserAgent = fmt.Sprintf("DiscordBot (%v, %v)", config.ThisInstanceURL, Version)
-				}
-			}
-
-			if session != nil {
-				handleEventScheduling(session, &state, config)
-			}
+			// Note: events that are sent via the webhook are only scheduled after the webhook was authorized
+			handleEventScheduling(clients, state, config)
 
 			time.Sleep(1 * time.Second)
 		}
 	}()
 
-	// print oauth-URL if no token is saved
+	// print oauth-URL if no token is saved and the webhook is required
 	check := ""
-	if state.AuthorizationToken == "" {
+	if _, _, authorized := state.Webhook(); !authorized && config.UsesWebhook() {
 		accessURL, newCheck := discord.GenerateWebhookOauthURL(
+			client,
 			config.ClientID,
 			config.ThisInstanceURL+WebhookTokenEndpoint)
 		check = newCheck
 		fmt.Println(accessURL)
 	}
 
-	handlerRouter := api.NewInteractionRouter()
-	handlerRouter.RegisterHandler(api.CustomIDButtonAddUserToGame, api.HandleAddUserToGame)
-	handlerRouter.RegisterHandler(api.CustomIDButtonRemoveUserFromEvent, api.HandleRemoveUserFromEvent)
+	latencyMetrics := api.NewLatencyMetrics()
+	messages := eventMessages(config)
+	handlerRouter.Use(api.Recover(), api.Logging(), latencyMetrics.Metrics())
+	// texts of the event messages use the configured locale of the event, replies the locale of the user
+	handlerRouter.Use(api.MessageLocale(func(interaction discord.Interaction) string {
+		event, ok := state.EventByMessageID(interaction.Message.ID)
+		if !ok {
+			return config.EventLocale("")
+		}
+		return config.EventLocale(event.Title)
+	}))
+	if config.EventThreads {
+		handlerRouter.Use(postAttendeeChanges(state, config))
+	}
+	handlerRouter.Use(notifyPromotedUsers(state, config))
+
+	rsvpOpen := api.Check(api.RequireOpenRsvp(func(interaction discord.Interaction) (time.Time, bool) {
+		event, ok := state.EventByMessageID(interaction.Message.ID)
+		if !ok {
+			return time.Time{}, false
+		}
+		return config.Events[event.Title].RsvpClosesAt(event.StartsAt)
+	}))
+	allowedRoles := api.Check(api.RequireRoles(func(interaction discord.Interaction) ([]string, bool) {
+		event, ok := state.EventByMessageID(interaction.Message.ID)
+		if !ok {
+			return nil, false
+		}
+		return config.Events[event.Title].AllowedRoles, true
+	}))
+	organiser := api.Check(api.RequireOrganiser(config.OrganiserRoles))
+
+	handlerRouter.RegisterHandler(api.CustomIDButtonAddUserToGame, messages.HandleAddUserToGame, rsvpOpen, allowedRoles)
+	handlerRouter.RegisterHandler(api.CustomIDButtonRemoveUserFromEvent, messages.HandleRemoveUserFromEvent, rsvpOpen)
+	handlerRouter.RegisterHandler(api.CustomIDButtonAddNote, messages.HandleShowNoteModal, rsvpOpen)
+	handlerRouter.RegisterHandler(api.CustomIDModalSubmitNote, messages.HandleSubmitNote, rsvpOpen)
+	handlerRouter.RegisterHandler(api.CustomIDButtonLockEvent, messages.HandleLockEvent, organiser)
+	handlerRouter.RegisterHandler(api.CustomIDButtonUnlockEvent, messages.HandleUnlockEvent, organiser)
+	handlerRouter.RegisterHandler(api.CustomIDButtonCancelEvent, messages.HandleCancelEvent, organiser, cancelScheduledEvent(state))
+	handlerRouter.RegisterHandler(api.CustomIDSelectKickAttendee, messages.HandleKickAttendee, organiser)
+
+	verifier := discord.NewVerifier(discordPubkey, config.MaxTimestampSkew())
+	http.Handle("/", handlerRouter.InteractionEndpoint(verifier))
+	if config.MetricsListenAddress != "" {
+		// Note: the metrics are served separately from the public endpoint for Discord
+		metrics := http.NewServeMux()
+		metrics.Handle(MetricsEndpoint, metricsHandler(latencyMetrics, verifier))
+		go func() {
+			fmt.Println("serving metrics on", config.MetricsListenAddress)
+			log.Fatal(http.ListenAndServe(config.MetricsListenAddress, metrics))
+		}()
+	}
+	if config.UsesWebhook() {
+		http.Handle(WebhookTokenEndpoint, webhookTokenHandler(client, state, config, check))
+	}
+
+	binding := fmt.Sprintf(":%v", port)
+	fmt.Println("listening on", binding)
+	log.Fatal(http.ListenAndServe(binding, nil))
+}
 
-	http.Handle("/", handlerRouter.InteractionEndpoint(discordPubkey))
-	http.Handle(WebhookTokenEndpoint, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+// metricsHandler reports the metrics of the interactions and the rejected requests as JSON.
+func metricsHandler(latencyMetrics *api.LatencyMetrics, verifier *discord.Verifier) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(struct {
+			Interactions     map[string]api.ActionMetrics `json:"interactions"`
+			RejectedRequests discord.VerifierCounters     `json:"rejected_requests"`
+		}{
+			Interactions:     latencyMetrics.Snapshot(),
+			RejectedRequests: verifier.Counters(),
+		})
+		if err != nil {
+			fmt.Printf("could not write metrics: %v\n", err)
+		}
+	})
+}
+
+// handleTokenRefresh refreshes the access token, if it expires within the next hour.
+func handleTokenRefresh(client *discord.Client, state *State, config Config) {
+	refreshToken, expiresAt := state.RefreshTokenExpiry()
+	if time.Until(expiresAt) < 1*time.Hour && refreshToken != "" {
+		token, err := discord.RefreshToken(
+			client,
+			config.ClientID,
+			config.ClientSecret,
+			refreshToken)
+		if err != nil {
+			fmt.Printf("could not refresh access token: %v\n", err)
+		} else {
+			state.SetToken(
+				token.TokenType,
+				token.AccessToken,
+				time.Now().Add(time.Duration(token.ExpiresIn)*time.Second),
+				token.RefreshToken)
+			fmt.Println("token was refreshed")
+		}
+	}
+}
+
+// webhookTokenHandler handles the OAuth2 redirect after a user selected the webhook channel.
+// The check must match the state of the URL created by discord.GenerateWebhookOauthURL.
+func webhookTokenHandler(client *discord.Client, state *State, config Config, check string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		query := r.URL.Query()
 		if stateStr := query.Get("state"); stateStr == check {
 			if code := query.Get("code"); code != "" {
 				token, err := discord.RequestToken(
+					client,
 					config.ClientID,
 					config.ClientSecret,
 					code,
@@ -125,9 +210,5 @@ func main() {
 			return
 		}
 		w.Write([]byte("Success!"))
-	}))
-
-	binding := fmt.Sprintf(":%v", port)
-	fmt.Println("listening on", binding)
-	log.Fatal(http.ListenAndServe(binding, nil))
+	})
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/discordtest"
+)
+
+func TestWebhookTokenFlow(t *testing.T) {
+	fake := discordtest.NewFakeDiscord()
+	defer fake.Close()
+	client := discord.NewClient(fake.BaseURL(), "DiscordBot (https://example.org, 1)")
+	state := resumeStateFrom(filepath.Join(t.TempDir(), stateFileName))
+	config := Config{
+		ThisInstanceURL: "https://example.org",
+		ClientID:        fake.ClientID,
+		ClientSecret:    fake.ClientSecret,
+	}
+
+	accessURL, check := discord.GenerateWebhookOauthURL(client, config.ClientID, config.ThisInstanceURL+WebhookTokenEndpoint)
+	parsed, err := url.Parse(accessURL)
+	if err != nil {
+		t.Fatalf("could not parse access URL: %v", err)
+	}
+	if parsed.Query().Get("state") != check {
+		t.Errorf("the access URL %v does not contain the state %v", accessURL, check)
+	}
+
+	handler := webhookTokenHandler(client, state, config, check)
+	code := fake.AuthorizationCode(config.ThisInstanceURL + WebhookTokenEndpoint)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, WebhookTokenEndpoint+"?state=wrong&code="+code, nil))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected status code %v for a wrong state, got %v", http.StatusUnauthorized, recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, WebhookTokenEndpoint+"?state="+check+"&code="+code, nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status code %v, got %v", http.StatusOK, recorder.Code)
+	}
+	if state.AuthorizationToken == "" || state.RefreshToken == "" || state.WebhookID == "" || state.WebhookToken == "" {
+		t.Fatalf("the token and webhook were not stored: %+v", state)
+	}
+
+	// the webhook of the token must be usable
+	_, err = discord.SendWebhookWithComponents(client, state.WebhookID, state.WebhookToken, false, discord.WebhookWithComponent{
+		Components: []discord.Component{{Type: discord.ComponentTypeActionRow}},
+	})
+	if err != nil {
+		t.Errorf("could not use the webhook: %v", err)
+	}
+
+	// refresh the token shortly before it expires
+	oldRefreshToken := state.RefreshToken
+	state.SetToken(state.AuthorizationTokenType, state.AuthorizationToken, time.Now().Add(time.Minute), state.RefreshToken)
+	handleTokenRefresh(client, state, config)
+	if state.RefreshToken == oldRefreshToken {
+		t.Errorf("the token was not refreshed")
+	}
+	if time.Until(state.ExpiresAt) < 24*time.Hour {
+		t.Errorf("the expiry of the refreshed token was not stored: %v", state.ExpiresAt)
+	}
+}
+
+func TestRequestTokenErrors(t *testing.T) {
+	fake := discordtest.NewFakeDiscord()
+	defer fake.Close()
+	client := discord.NewClient(fake.BaseURL(), "DiscordBot (https://example.org, 1)")
+
+	redirectURI := "https://example.org" + WebhookTokenEndpoint
+	code := fake.AuthorizationCode(redirectURI)
+	_, err := discord.RequestToken(client, fake.ClientID, "wrong-secret", code, redirectURI)
+	if err == nil || discord.IsRetryable(err) {
+		t.Errorf("expected a permanent error for a wrong client secret, got %v", err)
+	}
+
+	_, err = discord.RequestToken(client, fake.ClientID, fake.ClientSecret, code, redirectURI)
+	if err != nil {
+		t.Fatalf("could not request token: %v", err)
+	}
+	// codes can only be used once
+	_, err = discord.RequestToken(client, fake.ClientID, fake.ClientSecret, code, redirectURI)
+	if err == nil {
+		t.Errorf("expected an error for a used code")
+	}
+}
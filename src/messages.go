@@ -0,0 +1,79 @@
+package main
+
+import (
+	"github.com/localthomas/discord-rsvp/discord"
+)
+
+// Event messages are either sent via the webhook of the OAuth2 flow or, in bot-token mode, posted by the bot user
+// to the channel of the event. The functions below choose the API depending on the event.
+
+func postEventMessage(clients discordClients, event RsvpEvent, message discord.WebhookWithComponent) (*discord.Message, error) {
+	if event.ChannelID != "" {
+		if clients.bot == nil {
+			return nil, errNoBotToken
+		}
+		return discord.CreateChannelMessage(clients.bot, event.ChannelID, message)
+	}
+	return discord.SendWebhookWithComponents(clients.webhook, event.WebhookID, event.WebhookToken, true, message)
+}
+
+func getEventMessage(clients discordClients, event RsvpEvent) (discord.WebhookWithComponent, error) {
+	if event.ChannelID != "" {
+		if clients.bot == nil {
+			return discord.WebhookWithComponent{}, errNoBotToken
+		}
+		return discord.GetChannelMessage(clients.bot, event.ChannelID, event.MessageID)
+	}
+	return discord.GetWebhookMessage(clients.webhook, event.WebhookID, event.WebhookToken, event.MessageID)
+}
+
+func editEventMessage(clients discordClients, event RsvpEvent, message discord.WebhookWithComponent) (*discord.Message, error) {
+	if event.ChannelID != "" {
+		if clients.bot == nil {
+			return nil, errNoBotToken
+		}
+		return discord.EditChannelMessage(clients.bot, event.ChannelID, event.MessageID, message)
+	}
+	return discord.EditWebhookMessage(clients.webhook, event.WebhookID, event.WebhookToken, event.MessageID, message)
+}
+
+// changeEventMessage applies the change to the current version of the event message and edits the message,
+// if change reports that the message was changed. Interaction responses for the message wait in the meantime,
+// and interactions for the previous version of the message are rejected afterwards, see api.MessageLocks.
+func changeEventMessage(clients discordClients, event RsvpEvent, change func(message *discord.WebhookWithComponent) bool) error {
+	unlock := clients.messages.Lock(event.MessageID)
+	defer unlock()
+	message, err := getEventMessage(clients, event)
+	if err != nil {
+		return err
+	}
+	if !change(&message) {
+		return nil
+	}
+	edited, err := editEventMessage(clients, event, message)
+	if err != nil {
+		return err
+	}
+	clients.messages.SetEdited(event.MessageID, edited.EditedTimestamp)
+	return nil
+}
+
+func deleteEventMessage(clients discordClients, event RsvpEvent) error {
+	var err error
+	if event.ChannelID != "" {
+		if clients.bot == nil {
+			return errNoBotToken
+		}
+		err = discord.DeleteChannelMessage(clients.bot, event.ChannelID, event.MessageID)
+	} else {
+		err = discord.DeleteWebhookMessage(clients.webhook, event.WebhookID, event.WebhookToken, event.MessageID)
+	}
+	if err == nil {
+		clients.messages.Forget(event.MessageID)
+	}
+	return err
+}
+
+// errNoBotToken is returned for requests that require a bot token, if none is configured.
+// It is a permanent error, so that such operations are not retried.
+var errNoBotToken = &discord.APIError{Message: "no bot token is configured"}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/localthomas/discord-rsvp/api"
+	"github.com/localthomas/discord-rsvp/discord"
+)
+
+// Kinds of operations, see Operation.
+const (
+	OperationSendEvent     = "send_event"
+	OperationRerenderEvent = "rerender_event"
+	OperationCloseRsvp     = "close_rsvp"
+	OperationDeleteEvent   = "delete_event"
+	// Guild Scheduled Events are only mirrored if a bot token is configured
+	OperationSyncScheduledEvent   = "sync_scheduled_event"
+	OperationCancelScheduledEvent = "cancel_scheduled_event"
+	OperationDeleteScheduledEvent = "delete_scheduled_event"
+	// Threads are only started on messages posted by the bot
+	OperationCreateThread  = "create_thread"
+	OperationPostToThread  = "post_to_thread"
+	OperationArchiveThread = "archive_thread"
+	OperationSendReminder  = "send_reminder"
+	// OperationConfirmGames marks the games as happening or not and posts a summary
+	OperationConfirmGames = "confirm_games"
+	// OperationPostLineup closes the RSVP at the start of the event and posts the attendees
+	OperationPostLineup = "post_lineup"
+	// OperationNotifyPromotion mentions the users that were moved from a waitlist to the attendees
+	OperationNotifyPromotion = "notify_promotion"
+)
+
+// discordClients holds the clients for the different kinds of authorization.
+type discordClients struct {
+	// webhook is used for webhook messages and OAuth2 requests, which are not authorized via a header
+	webhook *discord.Client
+	// bot sends requests on behalf of the bot user and is nil, if no BotToken is configured
+	bot *discord.Client
+	// messages serialises the changes of event messages with the interaction responses of the router
+	messages *api.MessageLocks
+}
+
+// maxOperationBackoff is the maximum delay between two attempts of a failed operation.
+const maxOperationBackoff = 15 * time.Minute
+
+// Operation is a pending request to Discord, which is stored in the state until it succeeded.
+// Operations are executed by processOperations in the order they were added.
+type Operation struct {
+	Kind string
+	// Event identifies the event and its message the operation is executed for
+	Event RsvpEvent
+	// ID distinguishes operations of the same kind for the same event, e.g. multiple posts to a thread
+	ID string
+	// Text is the content of a post to a thread or of a notification, or the users mentioned in a part of a reminder
+	Text        string
+	Attempts    int
+	NextAttempt time.Time
+}
+
+// Key identifies an operation, so that the same operation is not added twice.
+func (o Operation) Key() string {
+	key := o.Kind + " " + o.Event.Title + " " + o.Event.StartsAt.UTC().Format(time.RFC3339)
+	if o.ID != "" {
+		key += " " + o.ID
+	}
+	return key
+}
+
+// processOperations executes all pending operations that are due.
+// Failed operations are retried with exponential backoff, or after the time given by Discord, if a rate limit was hit.
+func processOperations(clients discordClients, state *State, config Config) {
+	for _, operation := range state.Operations() {
+		if operation.Kind == OperationSendEvent && !shouldSendEvent(config, operation.Event) {
+			fmt.Printf("dropping operation %v, since the event is not upcoming anymore\n", operation.Key())
+			state.RemoveOperation(operation)
+			continue
+		}
+		if time.Now().Before(operation.NextAttempt) {
+			continue
+		}
+
+		err := executeOperation(clients, state, config, operation)
+		if err == nil {
+			state.RemoveOperation(operation)
+			continue
+		}
+
+		operation.Attempts++
+		var rateLimitError *discord.RateLimitError
+		switch {
+		case errors.As(err, &rateLimitError):
+			operation.NextAttempt = time.Now().Add(rateLimitError.RetryAfter)
+		case !discord.IsRetryable(err) && operation.Kind != OperationSendEvent:
+			// Note: sending is always retried, since the scheduler would add the event again anyway
+			fmt.Printf("dropping operation %v after a permanent error: %v\n", operation.Key(), err)
+			state.RemoveOperation(operation)
+			continue
+		default:
+			operation.NextAttempt = time.Now().Add(operationBackoff(operation.Attempts))
+		}
+		fmt.Printf("operation %v failed (attempt %v), retrying at %v: %v\n", operation.Key(), operation.Attempts, operation.NextAttempt.Format(time.RFC3339), err)
+		state.UpdateOperation(operation)
+
+		if rateLimitError != nil && rateLimitError.Global {
+			// all other requests would fail as well
+			return
+		}
+	}
+}
+
+func executeOperation(clients discordClients, state *State, config Config, operation Operation) error {
+	event := operation.Event
+	switch operation.Kind {
+	case OperationSendEvent:
+		return sendEvent(clients, state, config, event)
+	case OperationRerenderEvent:
+		// Note: render the current state of the event, e.g. with the link to a scheduled event created in the meantime
+		if current, ok := state.RsvpEvent(event.Title, event.StartsAt); ok {
+			event = current
+		}
+		rendered := createEventMessage(event, config)
+		return rerenderEvent(clients, eventMessages(config), event, rendered, config.EventLocale(event.Title))
+	case OperationCloseRsvp:
+		return closeRsvp(clients, event, config.EventLocale(event.Title))
+	case OperationDeleteEvent:
+		return deleteEventMessage(clients, event)
+	case OperationSendReminder:
+		return sendReminder(clients, state, config, operation)
+	case OperationConfirmGames:
+		return confirmGames(clients, state, config, event)
+	case OperationPostLineup:
+		return postLineup(clients, state, config, event)
+	case OperationNotifyPromotion:
+		return sendPromotion(clients, event, operation.Text)
+	case OperationSyncScheduledEvent, OperationCancelScheduledEvent, OperationDeleteScheduledEvent:
+		if clients.bot == nil {
+			return errNoBotToken
+		}
+		switch operation.Kind {
+		case OperationSyncScheduledEvent:
+			return syncScheduledEvent(clients.bot, state, config, event)
+		case OperationCancelScheduledEvent:
+			return discord.CancelGuildScheduledEvent(clients.bot, config.GuildID, event.ScheduledEventID)
+		default:
+			return discord.DeleteGuildScheduledEvent(clients.bot, config.GuildID, event.ScheduledEventID)
+		}
+	case OperationCreateThread, OperationPostToThread, OperationArchiveThread:
+		if clients.bot == nil {
+			return errNoBotToken
+		}
+		switch operation.Kind {
+		case OperationCreateThread:
+			return createEventThread(clients.bot, state, event)
+		case OperationPostToThread:
+			return postToThread(clients.bot, event.ThreadID, operation.Text)
+		default:
+			return discord.ArchiveThread(clients.bot, event.ThreadID)
+		}
+	default:
+		// Note: unknown operations can only be the result of a downgrade and are dropped
+		return &discord.APIError{Message: fmt.Sprintf("unknown operation %v", operation.Kind)}
+	}
+}
+
+// shouldSendEvent reports if the message of the event is still needed, i.e. the event did not start yet
+// and is still configured. Otherwise a failed sending of the message would be retried forever.
+func shouldSendEvent(config Config, event RsvpEvent) bool {
+	_, ok := config.Events[event.Title]
+	return ok && time.Now().Before(event.StartsAt)
+}
+
+// operationBackoff returns the delay after the given number of failed attempts.
+func operationBackoff(attempts int) time.Duration {
+	backoff := time.Second
+	for i := 1; i < attempts && backoff < maxOperationBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxOperationBackoff {
+		return maxOperationBackoff
+	}
+	return backoff
+}
+
+// rerenderEvent replaces the embed for the event and the components of the message,
+// while keeping the attendees and the status of the message.
+func rerenderEvent(clients discordClients, messages api.EventMessages, event RsvpEvent, rendered discord.WebhookWithComponent, locale string) error {
+	return changeEventMessage(clients, event, func(message *discord.WebhookWithComponent) bool {
+		// Note: the state knows about cancellations of messages that were cancelled before their status was stored in the message
+		*message = messages.RerenderEventMessage(*message, rendered, locale, event.Cancelled)
+		return true
+	})
+}
+
+// closeRsvp disables all components of the event message.
+func closeRsvp(clients discordClients, event RsvpEvent, locale string) error {
+	return changeEventMessage(clients, event, func(message *discord.WebhookWithComponent) bool {
+		api.CloseRsvpMessage(message, locale)
+		return true
+	})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/localthomas/discord-rsvp/api"
+	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/i18n"
+)
+
+// maxReminderMentions is the maximum number of users in the allowed_mentions of a message.
+const maxReminderMentions = 100
+
+// scheduleReminder adds an operation for the latest reminder of the event that is due and was not sent yet.
+// If several reminders are due at once, e.g. after a downtime, only the latest one is sent and the others are skipped.
+func scheduleReminder(state *State, config Config, event RsvpEvent) {
+	due := config.Events[event.Title].DueReminders(event.StartsAt, time.Now())
+	unsent := []string{}
+	for _, offset := range due {
+		if !containsString(event.RemindersSent, offset.String()) {
+			unsent = append(unsent, offset.String())
+		}
+	}
+	if len(unsent) == 0 {
+		return
+	}
+	state.AddOperation(Operation{
+		Kind:  OperationSendReminder,
+		Event: event,
+		ID:    unsent[len(unsent)-1],
+	})
+	state.AddRemindersSent(event.Title, event.StartsAt, unsent)
+}
+
+// sendReminder mentions everyone who signed up for a game of the event in a new message next to the event message.
+// Nothing is sent, if nobody signed up or the event was cancelled or started in the meantime,
+// e.g. since the reminder was retried.
+// If the attendees do not fit into one message, the first part is sent and the other parts are added as operations,
+// which contain the mentioned users in their text, so that a retry does not send a part twice.
+func sendReminder(clients discordClients, state *State, config Config, operation Operation) error {
+	current, ok := state.RsvpEvent(operation.Event.Title, operation.Event.StartsAt)
+	if !ok || current.Cancelled || !time.Now().Before(current.StartsAt) {
+		return nil
+	}
+	userIDs := strings.Fields(operation.Text)
+	if len(userIDs) == 0 {
+		message, err := getEventMessage(clients, current)
+		if err != nil {
+			return err
+		}
+		userIDs = attendeeIDs(api.Attendees(message))
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+	parts := reminderParts(current, config, userIDs)
+	_, err := postEventMessage(clients, current, reminderMessage(current, config, parts[0]))
+	if err != nil {
+		return err
+	}
+	for index, part := range parts[1:] {
+		state.AddOperation(Operation{
+			Kind:  OperationSendReminder,
+			Event: current,
+			ID:    fmt.Sprintf("%v/%v", operation.ID, index+2),
+			Text:  strings.Join(part, " "),
+		})
+	}
+	return nil
+}
+
+// reminderParts splits the users into groups, whose reminders stay below the 2000 characters allowed by Discord
+// and below the limit of allowed_mentions.
+func reminderParts(event RsvpEvent, config Config, userIDs []string) [][]string {
+	parts := [][]string{}
+	part := []string{}
+	length := utf8.RuneCountInString(reminderMessage(event, config, nil).Content)
+	for _, userID := range userIDs {
+		mention := utf8.RuneCountInString(userMention(userID)) + 1
+		if len(part) > 0 && (len(part) >= maxReminderMentions || length+mention > maxMessageContentLength) {
+			parts = append(parts, part)
+			part = []string{}
+			length = utf8.RuneCountInString(reminderMessage(event, config, nil).Content)
+		}
+		part = append(part, userID)
+		length += mention
+	}
+	return append(parts, part)
+}
+
+// reminderMessage creates the reminder for the event, which only notifies the given users.
+func reminderMessage(event RsvpEvent, config Config, userIDs []string) discord.WebhookWithComponent {
+	mentions := []string{}
+	for _, userID := range userIDs {
+		mentions = append(mentions, userMention(userID))
+	}
+	locale := config.EventLocale(event.Title)
+	content := i18n.Sprintf(locale, "Reminder: **%v** starts %v.", event.Title, formatStartTime(event, config)) + "\n" + strings.Join(mentions, " ")
+	return discord.WebhookWithComponent{
+		WebhookParams: discordgo.WebhookParams{
+			Content: content,
+			AllowedMentions: &discordgo.MessageAllowedMentions{
+				// Note: an empty list of mention types is required, if users are given
+				Parse: []discordgo.AllowedMentionType{},
+				Users: userIDs,
+			},
+		},
+	}
+}
+
+func userMention(userID string) string {
+	return fmt.Sprintf("<@%v>", userID)
+}
+
+// attendeeIDs returns the users that signed up for any game, in the order of the games and without duplicates.
+func attendeeIDs(attendees map[string][]string) []string {
+	games := []string{}
+	for game := range attendees {
+		games = append(games, game)
+	}
+	sort.Strings(games)
+	userIDs := []string{}
+	for _, game := range games {
+		for _, userID := range attendees[game] {
+			if !containsString(userIDs, userID) {
+				userIDs = append(userIDs, userID)
+			}
+		}
+	}
+	return userIDs
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
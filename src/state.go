@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"log"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 )
 
@@ -13,13 +15,21 @@ const stateFilePath = stateFileDir + stateFileName
 
 // State stores the application state. DO NOT USE FIELDS DIRECTLY!
 type State struct {
+	// mutex guards the fields, since the state is read by the HTTP handlers and modified by the scheduler
+	mutex sync.RWMutex
+	// path is the file the state is saved to
+	path string
+
 	AuthorizationTokenType string
 	AuthorizationToken     string
 	ExpiresAt              time.Time
 	RefreshToken           string
 	WebhookID              string
 	WebhookToken           string
-	Events                 []RsvpEvent
+	// Note: the events are saved as "Events" for compatibility with existing state files
+	RsvpEvents []RsvpEvent `json:"Events"`
+	// PendingOperations contains the requests to Discord that were not successful yet
+	PendingOperations []Operation
 }
 
 // RsvpEvent stores the webhook message ids for an event that is currently in the rsvp phase.
@@ -28,23 +38,53 @@ type RsvpEvent struct {
 	StartsAt     time.Time
 	WebhookID    string
 	WebhookToken string
-	MessageID    string
+	// ChannelID is set instead of the webhook, if the message was posted by the bot user
+	ChannelID string
+	MessageID string
+	// RsvpClosed is true, when the components of the message were disabled because of the RSVP deadline
+	RsvpClosed bool
+	// RenderHash identifies the configuration the message was rendered with, see renderHash
+	RenderHash string
+	// Cancelled is true, when an organiser cancelled the event
+	Cancelled bool
+	// ScheduledEventID is the ID of the Guild Scheduled Event that mirrors the event, if it was created
+	ScheduledEventID string
+	// ScheduledEventHash identifies the configuration the scheduled event was last synchronised with
+	ScheduledEventHash string
+	// ThreadID is the ID of the thread started on the message, if EventThreads is enabled
+	ThreadID string
+	// RemindersSent contains the reminders that were sent or skipped, as durations before the start, e.g. "15m0s"
+	RemindersSent []string
+	// ConfirmationScheduled is true, when the operation for confirming the games was added
+	ConfirmationScheduled bool
+	// GamesHappening maps the title of each game to whether it is happening, once the games were confirmed
+	GamesHappening map[string]bool
+	// LineupScheduled is true, when the operation for posting the final lineup was added
+	LineupScheduled bool
+}
+
+func ResumeState() *State {
+	return resumeStateFrom(stateFilePath)
 }
 
-func ResumeState() State {
-	data, err := os.ReadFile(stateFilePath)
+// resumeStateFrom reads the state from the file at path, which is also used for saving the state.
+func resumeStateFrom(path string) *State {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return State{}
+		return &State{path: path}
 	}
-	state := State{}
-	err = json.Unmarshal(data, &state)
+	state := &State{}
+	err = json.Unmarshal(data, state)
 	if err != nil {
-		return State{}
+		return &State{path: path}
 	}
+	state.path = path
 	return state
 }
 
 func (s *State) SetToken(tokenType, token string, expiresAt time.Time, refreshToken string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	s.AuthorizationTokenType = tokenType
 	s.AuthorizationToken = token
 	s.ExpiresAt = expiresAt
@@ -53,37 +93,220 @@ func (s *State) SetToken(tokenType, token string, expiresAt time.Time, refreshTo
 }
 
 func (s *State) SetWebhook(id, token string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	s.WebhookID = id
 	s.WebhookToken = token
 	s.save()
 }
 
+// Webhook returns the ID and token of the webhook and whether it was authorized.
+func (s *State) Webhook() (string, string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.WebhookID, s.WebhookToken, s.AuthorizationToken != ""
+}
+
+// RefreshTokenExpiry returns the refresh token and the time the access token expires at.
+func (s *State) RefreshTokenExpiry() (string, time.Time) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.RefreshToken, s.ExpiresAt
+}
+
+// Events returns a copy of the events, which is not changed by later modifications of the state.
+func (s *State) Events() []RsvpEvent {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return append([]RsvpEvent(nil), s.RsvpEvents...)
+}
+
 func (s *State) AddRsvpEvent(event RsvpEvent) {
-	s.Events = append(s.Events, event)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.RsvpEvents = append(s.RsvpEvents, event)
+	s.save()
+}
+
+// EventByMessageID returns the event that belongs to the webhook message with the given ID.
+func (s *State) EventByMessageID(messageID string) (RsvpEvent, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, event := range s.RsvpEvents {
+		if event.MessageID == messageID {
+			return event, true
+		}
+	}
+	return RsvpEvent{}, false
+}
+
+// SetRsvpClosed marks the RSVP of the event as closed.
+func (s *State) SetRsvpClosed(title string, startsAt time.Time) {
+	s.updateRsvpEvent(title, startsAt, func(event *RsvpEvent) {
+		event.RsvpClosed = true
+	})
+}
+
+// SetCancelled marks the event as cancelled.
+func (s *State) SetCancelled(title string, startsAt time.Time) {
+	s.updateRsvpEvent(title, startsAt, func(event *RsvpEvent) {
+		event.Cancelled = true
+	})
+}
+
+// SetConfirmationScheduled marks that the games of the event are being confirmed.
+func (s *State) SetConfirmationScheduled(title string, startsAt time.Time) {
+	s.updateRsvpEvent(title, startsAt, func(event *RsvpEvent) {
+		event.ConfirmationScheduled = true
+	})
+}
+
+// SetGamesHappening stores which games of the event are happening.
+func (s *State) SetGamesHappening(title string, startsAt time.Time, happening map[string]bool) {
+	s.updateRsvpEvent(title, startsAt, func(event *RsvpEvent) {
+		event.GamesHappening = happening
+	})
+}
+
+// SetLineupScheduled marks that the final lineup of the event is being posted.
+func (s *State) SetLineupScheduled(title string, startsAt time.Time) {
+	s.updateRsvpEvent(title, startsAt, func(event *RsvpEvent) {
+		event.LineupScheduled = true
+	})
+}
+
+// SetScheduledEventID stores the ID of the Guild Scheduled Event that mirrors the event.
+func (s *State) SetScheduledEventID(title string, startsAt time.Time, scheduledEventID string) {
+	s.updateRsvpEvent(title, startsAt, func(event *RsvpEvent) {
+		event.ScheduledEventID = scheduledEventID
+	})
+}
+
+// SetScheduledEventHash stores the hash of the configuration the Guild Scheduled Event was last synchronised with.
+func (s *State) SetScheduledEventHash(title string, startsAt time.Time, hash string) {
+	s.updateRsvpEvent(title, startsAt, func(event *RsvpEvent) {
+		event.ScheduledEventHash = hash
+	})
+}
+
+// SetThreadID stores the ID of the thread that was started on the message of the event.
+func (s *State) SetThreadID(title string, startsAt time.Time, threadID string) {
+	s.updateRsvpEvent(title, startsAt, func(event *RsvpEvent) {
+		event.ThreadID = threadID
+	})
+}
+
+// AddRemindersSent records the reminders as sent, so that they are not sent again after a restart.
+func (s *State) AddRemindersSent(title string, startsAt time.Time, reminders []string) {
+	s.updateRsvpEvent(title, startsAt, func(event *RsvpEvent) {
+		event.RemindersSent = append(event.RemindersSent, reminders...)
+	})
+}
+
+// RsvpEvent returns the event with the given title and start time.
+func (s *State) RsvpEvent(title string, startsAt time.Time) (RsvpEvent, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, event := range s.RsvpEvents {
+		if event.Title == title && event.StartsAt.Equal(startsAt) {
+			return event, true
+		}
+	}
+	return RsvpEvent{}, false
+}
+
+// SetRenderHash stores the hash of the configuration the message of the event was last rendered with.
+func (s *State) SetRenderHash(title string, startsAt time.Time, renderHash string) {
+	s.updateRsvpEvent(title, startsAt, func(event *RsvpEvent) {
+		event.RenderHash = renderHash
+	})
+}
+
+// AddOperation appends the operation to the pending operations, if no operation with the same key is pending.
+func (s *State) AddOperation(operation Operation) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, pending := range s.PendingOperations {
+		if pending.Key() == operation.Key() {
+			return false
+		}
+	}
+	s.PendingOperations = append(s.PendingOperations, operation)
 	s.save()
+	return true
+}
+
+// Operations returns a copy of the pending operations.
+func (s *State) Operations() []Operation {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return append([]Operation(nil), s.PendingOperations...)
+}
+
+// UpdateOperation replaces the pending operation with the same key.
+func (s *State) UpdateOperation(operation Operation) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.PendingOperations {
+		if s.PendingOperations[i].Key() == operation.Key() {
+			s.PendingOperations[i] = operation
+			s.save()
+			return
+		}
+	}
+}
+
+// RemoveOperation removes the pending operation with the same key.
+func (s *State) RemoveOperation(operation Operation) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	remaining := make([]Operation, 0, len(s.PendingOperations))
+	for _, pending := range s.PendingOperations {
+		if pending.Key() != operation.Key() {
+			remaining = append(remaining, pending)
+		}
+	}
+	s.PendingOperations = remaining
+	s.save()
+}
+
+// updateRsvpEvent applies the update function to the event with the given title and start time and saves the state.
+func (s *State) updateRsvpEvent(title string, startsAt time.Time, update func(event *RsvpEvent)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i := range s.RsvpEvents {
+		if s.RsvpEvents[i].Title == title && s.RsvpEvents[i].StartsAt.Equal(startsAt) {
+			update(&s.RsvpEvents[i])
+			s.save()
+			return
+		}
+	}
 }
 
 func (s *State) RemoveRsvpEvent(title string, startsAt time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	// find the index of the event to delete it
 	index := -1
-	for i, event := range s.Events {
+	for i, event := range s.RsvpEvents {
 		if event.Title == title && event.StartsAt.Equal(startsAt) {
 			index = i
 		}
 	}
 
 	if index >= 0 {
-		if index+1 == len(s.Events) {
-			s.Events = s.Events[:index]
+		if index+1 == len(s.RsvpEvents) {
+			s.RsvpEvents = s.RsvpEvents[:index]
 		} else {
-			s.Events = append(s.Events[:index], s.Events[index+1:]...)
+			s.RsvpEvents = append(s.RsvpEvents[:index], s.RsvpEvents[index+1:]...)
 		}
 		s.save()
 	}
 }
 
+// save writes the state to disk. The caller must hold the lock.
 func (s *State) save() {
-	err := os.MkdirAll(stateFileDir, os.ModeDir|0700)
+	err := os.MkdirAll(filepath.Dir(s.path), os.ModeDir|0700)
 	if err != nil {
 		log.Fatalf("could not create state directory: %v", err)
 	}
@@ -91,7 +314,7 @@ func (s *State) save() {
 	if err != nil {
 		log.Fatalf("could not marshal state: %v", err)
 	}
-	err = os.WriteFile(stateFilePath, data, 0700)
+	err = os.WriteFile(s.path, data, 0700)
 	if err != nil {
 		log.Fatalf("could not save state: %v", err)
 	}
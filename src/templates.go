@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/localthomas/discord-rsvp/api"
+	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/i18n"
+)
+
+// Layout customises the event messages. All fields are optional, empty fields use the default layout.
+// The texts are templates of the package text/template, see eventTemplateData for the available data.
+type Layout struct {
+	// Title, Description and Footer of the embed for the event
+	Title       string
+	Description string
+	Footer      string
+	// Color of the embed for the event as hex value, e.g. "#01579b"
+	Color string
+	// GameFieldName and GameFieldValue describe a game in the embed for the event, see gameTemplateData
+	GameFieldName  string
+	GameFieldValue string
+	// GameButtonLabel is the label of the button for signing up for a game, see gameTemplateData
+	GameButtonLabel string
+	// GameButtonStyle is one of "primary", "secondary", "success" and "danger"
+	GameButtonStyle   string
+	RemoveButtonLabel string
+	NoteButtonLabel   string
+	// LockButtonLabel, UnlockButtonLabel and CancelButtonLabel are the labels of the buttons for organisers,
+	// see organiserTemplateData
+	LockButtonLabel   string
+	UnlockButtonLabel string
+	CancelButtonLabel string
+	// LockButtonStyle and CancelButtonStyle are one of "primary", "secondary", "success" and "danger"
+	LockButtonStyle   string
+	CancelButtonStyle string
+	// AttendeesTitle is the title of the embed for the attendees, see attendeesTemplateData
+	AttendeesTitle string
+	// AttendeesColor is the color of the embed for the attendees as hex value, e.g. "#3ba55d"
+	AttendeesColor string
+}
+
+// The default templates render the same message as before templates were introduced.
+const (
+	defaultTitleTemplate             = `{{.Title}}`
+	defaultDescriptionTemplate       = `{{.T "Event starts %v.\nSelect the games you want to play via the buttons below." .Start}}`
+	defaultFooterTemplate            = ``
+	defaultColor                     = 0x01579b
+	defaultGameFieldNameTemplate     = `{{with .Game.Emoji}}{{.}} {{end}}{{.Game.Title}}`
+	defaultGameFieldValueTemplate    = `{{.Game.Description}}{{with .Players}}` + "\n" + `{{.}}{{end}}{{with .Game.URL}}` + "\n" + `[{{$.T "More information"}}]({{.}}){{end}}{{with .Game.Image}}` + "\n" + `[{{$.T "Image"}}]({{.}}){{end}}{{with .Confirmation}}` + "\n" + `**{{.}}**{{end}}`
+	defaultGameButtonLabelTemplate   = `{{.Game.Title}}`
+	defaultGameButtonStyle           = discord.ButtonStyleSuccess
+	defaultRemoveButtonLabelTemplate = `{{.T "Remove Me"}}`
+	defaultNoteButtonLabelTemplate   = `{{.T "Add Note"}}`
+	defaultLockButtonLabelTemplate   = `{{.T "Lock RSVP"}}`
+	defaultUnlockButtonLabelTemplate = `{{.T "Unlock RSVP"}}`
+	defaultCancelButtonLabelTemplate = `{{.T "Cancel Event"}}`
+	defaultLockButtonStyle           = discord.ButtonStyleSecondary
+	defaultCancelButtonStyle         = discord.ButtonStyleDanger
+	defaultAttendeesTitleTemplate    = `{{.T "Attendees"}}`
+	defaultAttendeesColor            = 0x3ba55d
+)
+
+// Maximum lengths of the texts of a message allowed by Discord.
+const (
+	maxEmbedTitleLength       = 256
+	maxEmbedDescriptionLength = 4096
+	maxEmbedFooterLength      = 2048
+	maxFieldNameLength        = 256
+	maxFieldValueLength       = 1024
+	maxButtonLabelLength      = 80
+)
+
+var buttonStyles = map[string]discord.ComponentStyle{
+	"primary":   discord.ButtonStylePrimary,
+	"secondary": discord.ButtonStyleSecondary,
+	"success":   discord.ButtonStyleSuccess,
+	"danger":    discord.ButtonStyleDanger,
+}
+
+// eventTemplateData is the data of the templates for the event.
+type eventTemplateData struct {
+	Title    string
+	StartsAt time.Time
+	// Start is the start of the event as localised timestamps, e.g. "at <t:1624219200:F> (<t:1624219200:R>)"
+	Start  string
+	Locale string
+	Games  []gameEntry
+	// ScheduledEventURL links the Guild Scheduled Event of the event, if it exists
+	ScheduledEventURL string
+}
+
+// T translates the text to the locale of the event and formats it with the arguments, see i18n.Sprintf.
+func (d eventTemplateData) T(text string, args ...interface{}) string {
+	return i18n.Sprintf(d.Locale, text, args...)
+}
+
+// gameTemplateData is the data of the templates for a game, which includes the data of the event.
+type gameTemplateData struct {
+	eventTemplateData
+	Game gameEntry
+	// Status is "happening" or "not happening", once the games of the event were confirmed, and empty before
+	Status string
+}
+
+// Game statuses of gameTemplateData, see confirmGames.
+const (
+	gameStatusHappening    = "happening"
+	gameStatusNotHappening = "not happening"
+)
+
+func newGameTemplateData(data eventTemplateData, game gameEntry, event RsvpEvent) gameTemplateData {
+	gameData := gameTemplateData{
+		eventTemplateData: data,
+		Game:              game,
+	}
+	if event.GamesHappening != nil {
+		gameData.Status = gameStatusNotHappening
+		if event.GamesHappening[game.Title] {
+			gameData.Status = gameStatusHappening
+		}
+	}
+	return gameData
+}
+
+// Confirmation describes the Status in the locale of the event, e.g. "✅ Happening".
+// If the games were not confirmed yet, an empty string is returned.
+func (d gameTemplateData) Confirmation() string {
+	switch d.Status {
+	case gameStatusHappening:
+		return "✅ " + d.T("Happening")
+	case gameStatusNotHappening:
+		return "❌ " + d.T("Not happening")
+	default:
+		return ""
+	}
+}
+
+// Players describes the number of players of the game in the locale of the event, e.g. "2–4 players".
+// If the game has no limits, an empty string is returned.
+func (d gameTemplateData) Players() string {
+	min, max := d.Game.MinPlayers, d.Game.MaxPlayers
+	switch {
+	case min > 0 && min == max:
+		return d.T("%v players", min)
+	case min > 0 && max > 0:
+		return d.T("%v–%v players", min, max)
+	case min > 0:
+		return d.T("at least %v players", min)
+	case max > 0:
+		return d.T("up to %v players", max)
+	default:
+		return ""
+	}
+}
+
+// attendeesTemplateData is the data of the template for the title of the embed for the attendees.
+type attendeesTemplateData struct {
+	Locale string
+	// Count is the number of distinct users that signed up for at least one game
+	Count int
+}
+
+// T translates the text to the locale of the event and formats it with the arguments, see i18n.Sprintf.
+func (d attendeesTemplateData) T(text string, args ...interface{}) string {
+	return i18n.Sprintf(d.Locale, text, args...)
+}
+
+// organiserTemplateData is the data of the templates for the labels of the buttons for organisers.
+// It only contains the locale, since the labels are switched by the handlers, which do not know the event.
+type organiserTemplateData struct {
+	Locale string
+}
+
+// T translates the text to the locale of the event and formats it with the arguments, see i18n.Sprintf.
+func (d organiserTemplateData) T(text string, args ...interface{}) string {
+	return i18n.Sprintf(d.Locale, text, args...)
+}
+
+// eventTemplates contains the parsed templates of a Layout.
+type eventTemplates struct {
+	title, description, footer                            *template.Template
+	gameFieldName, gameFieldValue, gameButtonLabel        *template.Template
+	removeButtonLabel, noteButtonLabel, attendeesTitle    *template.Template
+	lockButtonLabel, unlockButtonLabel, cancelButtonLabel *template.Template
+	color, attendeesColor                                 int
+	gameButtonStyle, lockButtonStyle, cancelButtonStyle   discord.ComponentStyle
+}
+
+// defaultTemplates are used for configurations that were not read via ReadConfig, e.g. in tests.
+var defaultTemplates = mustParseTemplates(Layout{})
+
+// parseTemplates parses the templates of the layout and renders them with example data,
+// so that errors are reported at startup.
+func parseTemplates(layout Layout) (*eventTemplates, error) {
+	templates := &eventTemplates{
+		color:             defaultColor,
+		attendeesColor:    defaultAttendeesColor,
+		gameButtonStyle:   defaultGameButtonStyle,
+		lockButtonStyle:   defaultLockButtonStyle,
+		cancelButtonStyle: defaultCancelButtonStyle,
+	}
+	parsers := []struct {
+		name        string
+		text        string
+		defaultText string
+		target      **template.Template
+	}{
+		{"Title", layout.Title, defaultTitleTemplate, &templates.title},
+		{"Description", layout.Description, defaultDescriptionTemplate, &templates.description},
+		{"Footer", layout.Footer, defaultFooterTemplate, &templates.footer},
+		{"GameFieldName", layout.GameFieldName, defaultGameFieldNameTemplate, &templates.gameFieldName},
+		{"GameFieldValue", layout.GameFieldValue, defaultGameFieldValueTemplate, &templates.gameFieldValue},
+		{"GameButtonLabel", layout.GameButtonLabel, defaultGameButtonLabelTemplate, &templates.gameButtonLabel},
+		{"RemoveButtonLabel", layout.RemoveButtonLabel, defaultRemoveButtonLabelTemplate, &templates.removeButtonLabel},
+		{"NoteButtonLabel", layout.NoteButtonLabel, defaultNoteButtonLabelTemplate, &templates.noteButtonLabel},
+		{"LockButtonLabel", layout.LockButtonLabel, defaultLockButtonLabelTemplate, &templates.lockButtonLabel},
+		{"UnlockButtonLabel", layout.UnlockButtonLabel, defaultUnlockButtonLabelTemplate, &templates.unlockButtonLabel},
+		{"CancelButtonLabel", layout.CancelButtonLabel, defaultCancelButtonLabelTemplate, &templates.cancelButtonLabel},
+		{"AttendeesTitle", layout.AttendeesTitle, defaultAttendeesTitleTemplate, &templates.attendeesTitle},
+	}
+	for _, parser := range parsers {
+		text := parser.text
+		if text == "" {
+			text = parser.defaultText
+		}
+		parsed, err := template.New(parser.name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template %v: %w", parser.name, err)
+		}
+		*parser.target = parsed
+	}
+
+	var err error
+	if layout.Color != "" {
+		if templates.color, err = parseColor(layout.Color); err != nil {
+			return nil, fmt.Errorf("invalid Color: %w", err)
+		}
+	}
+	if layout.AttendeesColor != "" {
+		if templates.attendeesColor, err = parseColor(layout.AttendeesColor); err != nil {
+			return nil, fmt.Errorf("invalid AttendeesColor: %w", err)
+		}
+	}
+	styles := []struct {
+		name   string
+		style  string
+		target *discord.ComponentStyle
+	}{
+		{"GameButtonStyle", layout.GameButtonStyle, &templates.gameButtonStyle},
+		{"LockButtonStyle", layout.LockButtonStyle, &templates.lockButtonStyle},
+		{"CancelButtonStyle", layout.CancelButtonStyle, &templates.cancelButtonStyle},
+	}
+	for _, style := range styles {
+		if style.style == "" {
+			continue
+		}
+		parsed, ok := buttonStyles[style.style]
+		if !ok {
+			return nil, fmt.Errorf("invalid %v %v: must be one of primary, secondary, success and danger", style.name, style.style)
+		}
+		*style.target = parsed
+	}
+
+	// render all templates once, e.g. to detect fields that do not exist
+	event := eventTemplateData{
+		Title:    "Example",
+		StartsAt: time.Now(),
+		Start:    "at <t:0:F> (<t:0:R>)",
+		Locale:   i18n.DefaultLocale,
+		Games: []gameEntry{{
+			Title: "Game",
+			Game: Game{
+				Description: "Description",
+				Emoji:       "🎲",
+				Image:       "https://example.org/game.png",
+				URL:         "https://example.org",
+				MinPlayers:  2,
+				MaxPlayers:  4,
+			},
+		}},
+	}
+	game := gameTemplateData{eventTemplateData: event, Game: event.Games[0], Status: gameStatusHappening}
+	checks := []struct {
+		template *template.Template
+		data     interface{}
+		required bool
+	}{
+		{templates.title, event, true},
+		{templates.description, event, false},
+		{templates.footer, event, false},
+		{templates.gameFieldName, game, true},
+		{templates.gameFieldValue, game, true},
+		{templates.gameButtonLabel, game, true},
+		{templates.removeButtonLabel, event, true},
+		{templates.noteButtonLabel, event, true},
+		{templates.lockButtonLabel, organiserTemplateData{Locale: i18n.DefaultLocale}, true},
+		{templates.unlockButtonLabel, organiserTemplateData{Locale: i18n.DefaultLocale}, true},
+		{templates.cancelButtonLabel, organiserTemplateData{Locale: i18n.DefaultLocale}, true},
+		{templates.attendeesTitle, attendeesTemplateData{Locale: i18n.DefaultLocale, Count: 1}, true},
+	}
+	for _, check := range checks {
+		text, err := executeTemplate(check.template, check.data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template %v: %w", check.template.Name(), err)
+		}
+		if check.required && strings.TrimSpace(text) == "" {
+			return nil, fmt.Errorf("invalid template %v: must not render an empty text", check.template.Name())
+		}
+	}
+	return templates, nil
+}
+
+func mustParseTemplates(layout Layout) *eventTemplates {
+	templates, err := parseTemplates(layout)
+	if err != nil {
+		panic(err)
+	}
+	return templates
+}
+
+// parseColor parses a hex color like "#01579b".
+func parseColor(color string) (int, error) {
+	value, err := strconv.ParseUint(strings.TrimPrefix(color, "#"), 16, 32)
+	if err != nil || value > 0xffffff {
+		return 0, fmt.Errorf("%v is not a hex color like #01579b", color)
+	}
+	return int(value), nil
+}
+
+func executeTemplate(tmpl *template.Template, data interface{}) (string, error) {
+	buffer := &bytes.Buffer{}
+	if err := tmpl.Execute(buffer, data); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// render executes the template and truncates the result to maxLength characters.
+// Templates were validated at startup, but can still fail for some data, e.g. when indexing the games.
+// In that case, the error is logged and the fallback is used.
+func render(tmpl *template.Template, data interface{}, maxLength int, fallback string) string {
+	text, err := executeTemplate(tmpl, data)
+	if err != nil {
+		fmt.Printf("could not render template %v: %v\n", tmpl.Name(), err)
+		return fallback
+	}
+	return truncate(text, maxLength)
+}
+
+// attendeeLayout returns the layout of the embed for the attendees of the event messages.
+func attendeeLayout(config Config) api.AttendeeLayout {
+	templates := config.eventTemplates()
+	return api.AttendeeLayout{
+		Title: func(locale string, attendees int) string {
+			data := attendeesTemplateData{
+				Locale: locale,
+				Count:  attendees,
+			}
+			return render(templates.attendeesTitle, data, maxEmbedTitleLength, i18n.Translate(locale, "Attendees"))
+		},
+		Color: templates.attendeesColor,
+	}
+}
+
+// eventMessages returns the settings of the handlers, that change the event messages.
+func eventMessages(config Config) api.EventMessages {
+	capacities := make(map[string]int)
+	for title, game := range config.Games {
+		if game.MaxPlayers > 0 {
+			capacities[title] = game.MaxPlayers
+		}
+	}
+	return api.EventMessages{
+		AttendeeLayout:  attendeeLayout(config),
+		OrganiserLayout: organiserLayout(config),
+		GameCapacities:  capacities,
+	}
+}
+
+// organiserLayout returns the labels of the buttons for organisers, that are switched by the handlers.
+func organiserLayout(config Config) api.OrganiserLayout {
+	templates := config.eventTemplates()
+	return api.OrganiserLayout{
+		LockLabel: func(locale string) string {
+			return render(templates.lockButtonLabel, organiserTemplateData{Locale: locale}, maxButtonLabelLength, i18n.Translate(locale, "Lock RSVP"))
+		},
+		UnlockLabel: func(locale string) string {
+			return render(templates.unlockButtonLabel, organiserTemplateData{Locale: locale}, maxButtonLabelLength, i18n.Translate(locale, "Unlock RSVP"))
+		},
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/localthomas/discord-rsvp/api"
+	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/discordtest"
+)
+
+func TestCreateEventMessageWithLayout(t *testing.T) {
+	config := newTestConfig(time.Now().Add(24 * time.Hour))
+	templates, err := parseTemplates(Layout{
+		Title:           `🎲 {{.Title}}`,
+		Footer:          `{{len .Games}} games`,
+		Color:           "#ff0000",
+		GameFieldValue:  `{{.Game.Description}} ({{.Title}})`,
+		GameButtonLabel: `Play {{.Game.Title}}`,
+		GameButtonStyle: "primary",
+		NoteButtonLabel: `{{.T "Note"}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.templates = templates
+	config.Locale = "de"
+
+	message := createEventMessage(RsvpEvent{Title: "Test-Event", StartsAt: config.Events["Test-Event"].FirstTime}, config)
+	embed := message.Embeds[0]
+	if embed.Title != "🎲 Test-Event" || embed.Color != 0xff0000 || embed.Footer == nil || embed.Footer.Text != "1 games" {
+		t.Errorf("unexpected embed %+v", embed)
+	}
+	if !strings.HasPrefix(embed.Description, "Die Veranstaltung beginnt am <t:") {
+		t.Errorf("expected the default description in German, got %q", embed.Description)
+	}
+	if value := embed.Fields[0].Value; value != "Description for Game1 (Test-Event)" {
+		t.Errorf("unexpected field value %q", value)
+	}
+	gameButton := message.Components[0].Components[0]
+	if gameButton.Label != "Play Game1" || gameButton.Style != discord.ButtonStylePrimary {
+		t.Errorf("unexpected game button %+v", gameButton)
+	}
+	if label := message.Components[1].Components[1].Label; label != "Notiz" {
+		t.Errorf("expected the translated note label, got %q", label)
+	}
+}
+
+func TestInvalidLayouts(t *testing.T) {
+	layouts := map[string]Layout{
+		"syntax error":     {Title: `{{.Title`},
+		"unknown field":    {Description: `{{.Organiser}}`},
+		"empty label":      {GameButtonLabel: `{{if false}}x{{end}}`},
+		"invalid color":    {Color: "blue"},
+		"invalid style":    {GameButtonStyle: "link"},
+		"attendees fields": {AttendeesTitle: `{{.Title}}`},
+		"organiser fields": {CancelButtonLabel: `{{.Title}}`},
+		"invalid lock":     {LockButtonStyle: "grey"},
+	}
+	for name, layout := range layouts {
+		if _, err := parseTemplates(layout); err == nil {
+			t.Errorf("expected an error for the layout with %v", name)
+		}
+	}
+}
+
+func TestAttendeeLayout(t *testing.T) {
+	templates, err := parseTemplates(Layout{
+		AttendeesTitle: `{{.Count}} attending`,
+		AttendeesColor: "#00ff00",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addGame1 := api.EncodeCustomID(api.CustomIDButtonAddUserToGame, "Game1")
+	message := discordtest.EventMessage("Event", addGame1)
+	response := eventMessages(Config{templates: templates}).HandleAddUserToGame(discordtest.ButtonInteraction(addGame1, "846600000000000001", message), "Game1")
+	attendees := response.Update.Embeds[1]
+	if attendees.Title != "1 attending" || attendees.Color != 0x00ff00 {
+		t.Errorf("unexpected embed for the attendees %+v", attendees)
+	}
+}
+
+func TestOrganiserLayout(t *testing.T) {
+	config := newTestConfig(time.Now().Add(24 * time.Hour))
+	templates, err := parseTemplates(Layout{
+		LockButtonLabel:   `🔒 {{.T "Lock RSVP"}}`,
+		UnlockButtonLabel: `🔓 Open again`,
+		CancelButtonLabel: `Call off`,
+		LockButtonStyle:   "primary",
+		CancelButtonStyle: "secondary",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.templates = templates
+
+	message := createEventMessage(RsvpEvent{Title: "Test-Event", StartsAt: config.Events["Test-Event"].FirstTime}, config)
+	buttons := message.Components[len(message.Components)-1].Components
+	lock, cancel := buttons[2], buttons[3]
+	if lock.Label != "🔒 Lock RSVP" || lock.Style != discord.ButtonStylePrimary {
+		t.Errorf("unexpected lock button %+v", lock)
+	}
+	if cancel.Label != "Call off" || cancel.Style != discord.ButtonStyleSecondary {
+		t.Errorf("unexpected cancel button %+v", cancel)
+	}
+
+	// the handlers switch the label of the lock button with the layout
+	interaction := discordtest.ButtonInteraction(lock.CustomID, "846600000000000001", discord.Message{WebhookWithComponent: message})
+	response := eventMessages(config).HandleLockEvent(interaction, "")
+	if label := response.Update.Components[len(message.Components)-1].Components[2].Label; label != "🔓 Open again" {
+		t.Errorf("expected the unlock label of the layout, got %q", label)
+	}
+}
+
+func TestCreateEventMessageWithGameDetails(t *testing.T) {
+	config := newTestConfig(time.Now().Add(24 * time.Hour))
+	config.Games["Game1"] = Game{
+		Description: "Description for Game1",
+		Emoji:       "<:dice:123456>",
+		ButtonStyle: "danger",
+		Image:       "https://example.org/game1.png",
+		URL:         "https://example.org/game1",
+		MinPlayers:  2,
+		MaxPlayers:  4,
+		Order:       1,
+	}
+	config.Games["Game2"] = Game{Description: "Description for Game2", Image: "https://example.org/game2.png", MaxPlayers: 5}
+
+	message := createEventMessage(RsvpEvent{Title: "Test-Event", StartsAt: config.Events["Test-Event"].FirstTime}, config)
+	embed := message.Embeds[0]
+	// Note: Game2 comes first, since it has the lower order
+	if embed.Fields[0].Name != "Game2" || embed.Fields[0].Value != "Description for Game2\nup to 5 players\n[Image](https://example.org/game2.png)" {
+		t.Errorf("unexpected first field %+v", embed.Fields[0])
+	}
+	expectedValue := "Description for Game1\n2–4 players\n[More information](https://example.org/game1)\n[Image](https://example.org/game1.png)"
+	if embed.Fields[1].Name != "<:dice:123456> Game1" || embed.Fields[1].Value != expectedValue {
+		t.Errorf("unexpected second field %+v", embed.Fields[1])
+	}
+	if embed.Thumbnail == nil || embed.Thumbnail.URL != "https://example.org/game2.png" {
+		t.Errorf("expected the image of Game2 as thumbnail, got %+v", embed.Thumbnail)
+	}
+	gameButton := message.Components[0].Components[1]
+	if gameButton.Style != discord.ButtonStyleDanger || gameButton.Emoji == nil || gameButton.Emoji.ID != "123456" {
+		t.Errorf("unexpected game button %+v", gameButton)
+	}
+	if message.Components[0].Components[0].Emoji != nil {
+		t.Errorf("expected no emoji on the button of Game2")
+	}
+}
+
+func TestGameFromString(t *testing.T) {
+	config := struct {
+		Games map[string]Game
+	}{}
+	data := `{"Games": {"Game1": "Description for Game1", "Game2": {"Description": "Description for Game2", "MaxPlayers": 4}}}`
+	if err := json.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatal(err)
+	}
+	if game := config.Games["Game1"]; game != (Game{Description: "Description for Game1"}) {
+		t.Errorf("unexpected Game1 %+v", game)
+	}
+	if game := config.Games["Game2"]; game != (Game{Description: "Description for Game2", MaxPlayers: 4}) {
+		t.Errorf("unexpected Game2 %+v", game)
+	}
+}
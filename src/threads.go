@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/localthomas/discord-rsvp/api"
+	"github.com/localthomas/discord-rsvp/discord"
+)
+
+// maxThreadNameLength is the maximum length of the name of a thread allowed by Discord.
+const maxThreadNameLength = 100
+
+// threadName returns the name of the discussion thread of the event, e.g. "Game Night – 2021-06-20".
+// Note: the date is formatted independent of the locale, since names of threads can not contain timestamps
+func threadName(event RsvpEvent) string {
+	return truncate(fmt.Sprintf("%v – %v", event.Title, event.StartsAt.Format("2006-01-02")), maxThreadNameLength)
+}
+
+// createEventThread starts the discussion thread on the message of the event, if it was not started yet.
+func createEventThread(client *discord.Client, state *State, event RsvpEvent) error {
+	// Note: the event of the operation might be outdated or already removed
+	current, ok := state.RsvpEvent(event.Title, event.StartsAt)
+	if !ok || current.ThreadID != "" {
+		return nil
+	}
+	thread, err := discord.StartThreadFromMessage(client, current.ChannelID, current.MessageID, threadName(current), discord.ThreadAutoArchiveOneWeek)
+	if err != nil {
+		return err
+	}
+	state.SetThreadID(current.Title, current.StartsAt, thread.ID)
+	return nil
+}
+
+// postToThread posts the text to the thread without notifying the mentioned users.
+func postToThread(client *discord.Client, threadID, text string) error {
+	_, err := discord.CreateChannelMessage(client, threadID, discord.WebhookWithComponent{
+		WebhookParams: discordgo.WebhookParams{
+			Content: text,
+			// Note: an empty list of mention types suppresses all notifications
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		},
+	})
+	return err
+}
+
+// postAttendeeChanges returns a middleware that posts the users that signed up for or left a game
+// to the thread of the event, after the event message was updated by an interaction.
+func postAttendeeChanges(state *State, config Config) api.Middleware {
+	return func(next api.InteractionHandler) api.InteractionHandler {
+		return func(interaction discord.Interaction, argument string) api.InteractionResponse {
+			if interaction.Message == nil {
+				return next(interaction, argument)
+			}
+			// Note: the handlers modify the message of the interaction
+			before := api.Attendees(interaction.Message.WebhookWithComponent)
+			response := next(interaction, argument)
+			if response.Update == nil {
+				return response
+			}
+			event, ok := state.EventByMessageID(interaction.Message.ID)
+			if !ok || event.ThreadID == "" {
+				return response
+			}
+			changes := api.AttendeeChanges(before, api.Attendees(*response.Update), config.EventLocale(event.Title))
+			if len(changes) == 0 {
+				return response
+			}
+			state.AddOperation(Operation{
+				Kind:  OperationPostToThread,
+				Event: event,
+				// Note: the interaction ID is unique, so that no post is dropped as duplicate
+				ID:   interaction.ID,
+				Text: strings.Join(changes, "\n"),
+			})
+			return response
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/localthomas/discord-rsvp/api"
+	"github.com/localthomas/discord-rsvp/discord"
+	"github.com/localthomas/discord-rsvp/i18n"
+)
+
+// notifyPromotedUsers returns a middleware that mentions the users who were moved from the waitlist of a game
+// to its attendees by an interaction, e.g. because another user left the game.
+func notifyPromotedUsers(state *State, config Config) api.Middleware {
+	return func(next api.InteractionHandler) api.InteractionHandler {
+		return func(interaction discord.Interaction, argument string) api.InteractionResponse {
+			if interaction.Message == nil {
+				return next(interaction, argument)
+			}
+			// Note: the handlers modify the message of the interaction
+			waitlists := api.Waitlists(interaction.Message.WebhookWithComponent)
+			response := next(interaction, argument)
+			if response.Update == nil {
+				return response
+			}
+			promotions := api.Promotions(waitlists, *response.Update)
+			if len(promotions) == 0 {
+				return response
+			}
+			event, ok := state.EventByMessageID(interaction.Message.ID)
+			if !ok {
+				return response
+			}
+			state.AddOperation(Operation{
+				Kind:  OperationNotifyPromotion,
+				Event: event,
+				// Note: the interaction ID is unique, so that no notification is dropped as duplicate
+				ID:   interaction.ID,
+				Text: promotionText(promotions, config.EventLocale(event.Title)),
+			})
+			return response
+		}
+	}
+}
+
+// promotionText mentions the promoted users with one line per game, sorted by the game title.
+func promotionText(promotions map[string][]string, locale string) string {
+	games := []string{}
+	for game := range promotions {
+		games = append(games, game)
+	}
+	sort.Strings(games)
+	lines := []string{}
+	for _, game := range games {
+		mentions := []string{}
+		for _, userID := range promotions[game] {
+			mentions = append(mentions, "<@"+userID+">")
+		}
+		lines = append(lines, i18n.Sprintf(locale, "%v: a spot in **%v** opened up, you were moved from the waitlist to the attendees.", strings.Join(mentions, " "), game))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sendPromotion posts the notification of promoted users next to the event message.
+func sendPromotion(clients discordClients, event RsvpEvent, text string) error {
+	_, err := postEventMessage(clients, event, discord.WebhookWithComponent{
+		WebhookParams: discordgo.WebhookParams{
+			Content: text,
+			AllowedMentions: &discordgo.MessageAllowedMentions{
+				// Note: the text only mentions the promoted users
+				Parse: []discordgo.AllowedMentionType{discordgo.AllowedMentionTypeUsers},
+			},
+		},
+	})
+	return err
+}